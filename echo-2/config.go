@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"echo-2/store"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// openStore picks the store named by USER_STORE: memory (the default),
+// durable with DATA_DIR, WAL_SYNC and SNAPSHOT_INTERVAL, postgres with
+// DATABASE_URL or mongo with MONGODB_URI and MONGODB_DATABASE. The returned
+// function closes the store's files or connections.
+func openStore(ctx context.Context) (store.UserStore, func(), error) {
+	switch kind := os.Getenv("USER_STORE"); kind {
+	case "", "memory":
+		return store.NewMemory(), func() {}, nil
+
+	case "durable":
+		dir := os.Getenv("DATA_DIR")
+		if dir == "" {
+			dir = "data"
+		}
+		opts := store.DurableOptions{Sync: store.SyncPolicy(os.Getenv("WAL_SYNC")), SnapshotInterval: 5 * time.Minute}
+		if v := os.Getenv("SNAPSHOT_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid SNAPSHOT_INTERVAL: %w", err)
+			}
+			opts.SnapshotInterval = d
+		}
+
+		s, rec, err := store.OpenDurable(dir, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Recovered %s: snapshot at %d, %d log records replayed", dir, rec.SnapshotLSN, rec.Replayed)
+		if rec.TornBytes > 0 {
+			log.Printf("Cut a torn record of %d bytes from the end of the log", rec.TornBytes)
+		}
+		return s, func() {
+			if err := s.Close(); err != nil {
+				log.Printf("Failed to close the user store: %v", err)
+			}
+		}, nil
+
+	case "postgres":
+		pool, err := pgxpool.New(ctx, os.Getenv("DATABASE_URL"))
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := store.NewPostgres(ctx, pool)
+		if err != nil {
+			pool.Close()
+			return nil, nil, err
+		}
+		return s, pool.Close, nil
+
+	case "mongo":
+		client, err := mongo.Connect(options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+		if err != nil {
+			return nil, nil, err
+		}
+		closeClient := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			client.Disconnect(ctx)
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := client.Ping(pingCtx, readpref.Primary()); err != nil {
+			closeClient()
+			return nil, nil, err
+		}
+
+		dbName := os.Getenv("MONGODB_DATABASE")
+		if dbName == "" {
+			dbName = "echo"
+		}
+		return store.NewMongo(client.Database(dbName)), closeClient, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown USER_STORE %q, want memory, durable, postgres or mongo", kind)
+	}
+}
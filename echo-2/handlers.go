@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"echo-2/store"
+
+	"github.com/labstack/echo/v4"
+)
+
+type userHandler struct {
+	store store.UserStore
+}
+
+type userRequest struct {
+	Name string `json:"name"`
+}
+
+func (h *userHandler) createUser(c echo.Context) error {
+	fmt.Println("create user")
+
+	req := new(userRequest)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	u, err := h.store.Create(c.Request().Context(), req.Name)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, u)
+}
+
+func (h *userHandler) getUserByID(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		fmt.Println("Invalid user ID error: ", err)
+
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
+	}
+
+	u, err := h.store.Get(c.Request().Context(), id)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, u)
+}
+
+// getAllUsers responds with the users keyed by id, like the map they were
+// kept in before the stores.
+func (h *userHandler) getAllUsers(c echo.Context) error {
+	users, err := h.store.List(c.Request().Context())
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	byID := make(map[int]store.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	return c.JSON(http.StatusOK, byID)
+}
+
+func (h *userHandler) updateUserByID(c echo.Context) error {
+	req := new(userRequest)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		fmt.Println("Invalid user ID error: ", err)
+
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
+	}
+
+	u, err := h.store.Update(c.Request().Context(), id, req.Name)
+	if err != nil {
+		return storeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, u)
+}
+
+// deleteUserByID succeeds for users that don't exist, deleting is
+// idempotent.
+func (h *userHandler) deleteUserByID(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
+	}
+
+	err = h.store.Delete(c.Request().Context(), id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return storeError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func storeError(c echo.Context, err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "User not found"})
+	}
+
+	c.Logger().Error(err)
+	return c.JSON(http.StatusInternalServerError, echo.Map{"error": "Internal server error"})
+}
@@ -3,111 +3,30 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
-	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-type (
-	user struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	}
-)
-
-var (
-	users          = map[int]*user{}
-	seq            = 1
-	lock           = sync.Mutex{}
-	allowedOrigins = []string{"https://labstack.com", "https://labstack.net"}
-)
-
-func createUser(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
-
-	fmt.Println("create user")
-
-	u := &user{
-		ID: seq,
-	}
-	if err := c.Bind(u); err != nil {
-		return err
-	}
-
-	users[u.ID] = u
-	seq++
-
-	fmt.Println("users: ", users)
-
-	return c.JSON(http.StatusOK, u)
-}
-
-func getUserByID(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
-
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		fmt.Println("Invalid user ID error: ", err)
-
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
-	}
-
-	user, exists := users[id]
-	if !exists {
-		return c.JSON(http.StatusNotFound, echo.Map{"error": "User not found"})
-	}
-
-	return c.JSON(http.StatusOK, user)
-}
-
-func getAllUsers(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
-
-	return c.JSON(http.StatusOK, users)
-}
-
-func updateUserByID(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
+var allowedOrigins = []string{"https://labstack.com", "https://labstack.net"}
 
-	u := new(user)
-	if err := c.Bind(u); err != nil {
-		return err
-	}
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	id, err := strconv.Atoi(c.Param("id"))
+	users, closeStore, err := openStore(ctx)
 	if err != nil {
-		fmt.Println("Invalid user ID error: ", err)
-
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
+		log.Fatalf("Failed to open the user store: %v", err)
 	}
+	defer closeStore()
 
-	users[id].Name = u.Name
+	h := &userHandler{store: users}
 
-	return c.JSON(http.StatusOK, users[id])
-}
-
-func deleteUserByID(c echo.Context) error {
-	lock.Lock()
-	defer lock.Unlock()
-
-	id, _ := strconv.Atoi(c.Param("id"))
-
-	delete(users, id)
-
-	return c.NoContent(http.StatusNoContent)
-}
-
-func main() {
 	e := echo.New()
 
 	e.Use(middleware.Logger())
@@ -117,14 +36,11 @@ func main() {
 		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
 	}))
 
-	e.GET("/users", getAllUsers)
-	e.POST("/users", createUser)
-	e.GET("/users/:id", getUserByID)
-	e.PUT("/users/:id", updateUserByID)
-	e.DELETE("/users/:id", deleteUserByID)
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	e.GET("/users", h.getAllUsers)
+	e.POST("/users", h.createUser)
+	e.GET("/users/:id", h.getUserByID)
+	e.PUT("/users/:id", h.updateUserByID)
+	e.DELETE("/users/:id", h.deleteUserByID)
 
 	go func() {
 		err := e.Start(":8080")
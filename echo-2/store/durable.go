@@ -0,0 +1,418 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy says when the log is flushed to disk with fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs every record before the write returns, no
+	// acknowledged write is ever lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs every DurableOptions.SyncInterval, a crash can
+	// lose the writes of the last interval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system. Writes survive the
+	// process crashing, not the machine.
+	SyncNever SyncPolicy = "never"
+)
+
+type DurableOptions struct {
+	Sync SyncPolicy
+	// SyncInterval defaults to one second.
+	SyncInterval time.Duration
+	// SnapshotInterval is how often a snapshot replaces the log, zero
+	// disables periodic snapshots.
+	SnapshotInterval time.Duration
+}
+
+// Recovery describes what Open found on disk.
+type Recovery struct {
+	SnapshotLSN uint64
+	Replayed    int
+	// TornBytes were cut from the end of the log, a record the process
+	// was writing when it stopped.
+	TornBytes int64
+}
+
+const snapshotFile = "snapshot.json"
+
+type snapshot struct {
+	LSN   uint64 `json:"lsn"`
+	Seq   int    `json:"seq"`
+	Users []User `json:"users"`
+}
+
+// Durable is the in-memory store backed by a write-ahead log in a
+// directory. Every change is appended to the log before it is applied, and
+// snapshots of the whole state let old log segments be removed. Open
+// restores the state from the newest snapshot and the log after it.
+type Durable struct {
+	mem  *Memory
+	dir  string
+	opts DurableOptions
+
+	// mu serializes writes, so the log has them in the order they were
+	// applied. Reads only go to mem.
+	mu       sync.Mutex
+	segment  *os.File
+	lsn      uint64
+	unsynced bool
+
+	// snapshotMu keeps two snapshots from running at once.
+	snapshotMu sync.Mutex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// OpenDurable recovers the store in dir, creating dir when needed.
+func OpenDurable(dir string, opts DurableOptions) (*Durable, Recovery, error) {
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncAlways
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, Recovery{}, fmt.Errorf("unknown sync policy %q", opts.Sync)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, Recovery{}, err
+	}
+
+	d := &Durable{mem: NewMemory(), dir: dir, opts: opts}
+	rec, err := d.recover()
+	if err != nil {
+		return nil, rec, err
+	}
+
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.background()
+
+	return d, rec, nil
+}
+
+func (d *Durable) recover() (Recovery, error) {
+	var rec Recovery
+
+	data, err := os.ReadFile(filepath.Join(d.dir, snapshotFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return rec, err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return rec, fmt.Errorf("%s: %w", snapshotFile, err)
+		}
+		for _, u := range snap.Users {
+			d.mem.put(u)
+		}
+		d.mem.seq = max(d.mem.seq, snap.Seq)
+		d.lsn = snap.LSN
+		rec.SnapshotLSN = snap.LSN
+	}
+
+	segments, err := listSegments(d.dir)
+	if err != nil {
+		return rec, err
+	}
+
+	for i, seg := range segments {
+		records, good, err := readSegment(seg.path)
+		last := i == len(segments)-1
+		if errors.Is(err, errTorn) && last {
+			info, statErr := os.Stat(seg.path)
+			if statErr != nil {
+				return rec, statErr
+			}
+			rec.TornBytes = info.Size() - good
+			if err := os.Truncate(seg.path, good); err != nil {
+				return rec, err
+			}
+		} else if err != nil {
+			return rec, fmt.Errorf("%s: %w", filepath.Base(seg.path), err)
+		}
+
+		for _, r := range records {
+			// records before the snapshot are already in it, when a crash
+			// came between writing a snapshot and removing old segments
+			if r.LSN <= d.lsn {
+				continue
+			}
+			if r.LSN != d.lsn+1 {
+				return rec, fmt.Errorf("%s: record %d follows %d, the log has a gap", filepath.Base(seg.path), r.LSN, d.lsn)
+			}
+			if err := d.apply(r); err != nil {
+				return rec, fmt.Errorf("%s: record %d: %w", filepath.Base(seg.path), r.LSN, err)
+			}
+			d.lsn = r.LSN
+			rec.Replayed++
+		}
+	}
+
+	// keep appending to the newest segment, or start the first one
+	path := filepath.Join(d.dir, segmentName(d.lsn+1))
+	if len(segments) > 0 {
+		path = segments[len(segments)-1].path
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return rec, err
+	}
+	d.segment = f
+	return rec, syncDir(d.dir)
+}
+
+func (d *Durable) apply(r walRecord) error {
+	switch r.Op {
+	case opCreate:
+		d.mem.put(User{ID: r.ID, Name: r.Name})
+	case opUpdate:
+		_, err := d.mem.Update(context.Background(), r.ID, r.Name)
+		return err
+	case opDelete:
+		return d.mem.Delete(context.Background(), r.ID)
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+	return nil
+}
+
+// write appends r to the log and applies it, d.mu must be held.
+func (d *Durable) write(r walRecord) error {
+	r.LSN = d.lsn + 1
+	buf, err := encodeRecord(r)
+	if err != nil {
+		return err
+	}
+	if _, err := d.segment.Write(buf); err != nil {
+		// a partial write leaves a torn record, nothing may follow it
+		return d.fail(err)
+	}
+	if d.opts.Sync == SyncAlways {
+		if err := d.segment.Sync(); err != nil {
+			return d.fail(err)
+		}
+	} else {
+		d.unsynced = true
+	}
+
+	d.lsn = r.LSN
+	return d.apply(r)
+}
+
+// fail closes the log after a failed write, later writes return the error.
+// The state on disk is recovered by the next Open.
+func (d *Durable) fail(err error) error {
+	d.segment.Close()
+	d.segment = nil
+	return fmt.Errorf("write-ahead log: %w", err)
+}
+
+var errClosed = errors.New("write-ahead log is closed")
+
+func (d *Durable) Create(ctx context.Context, name string) (User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.segment == nil {
+		return User{}, errClosed
+	}
+
+	u := User{ID: d.mem.nextID(), Name: name}
+	if err := d.write(walRecord{Op: opCreate, ID: u.ID, Name: u.Name}); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (d *Durable) Get(ctx context.Context, id int) (User, error) {
+	return d.mem.Get(ctx, id)
+}
+
+func (d *Durable) List(ctx context.Context) ([]User, error) {
+	return d.mem.List(ctx)
+}
+
+func (d *Durable) Update(ctx context.Context, id int, name string) (User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.segment == nil {
+		return User{}, errClosed
+	}
+
+	if _, err := d.mem.Get(ctx, id); err != nil {
+		return User{}, err
+	}
+	if err := d.write(walRecord{Op: opUpdate, ID: id, Name: name}); err != nil {
+		return User{}, err
+	}
+	return User{ID: id, Name: name}, nil
+}
+
+func (d *Durable) Delete(ctx context.Context, id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.segment == nil {
+		return errClosed
+	}
+
+	if _, err := d.mem.Get(ctx, id); err != nil {
+		return err
+	}
+	return d.write(walRecord{Op: opDelete, ID: id})
+}
+
+// Sync flushes the log to disk.
+func (d *Durable) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.syncLocked()
+}
+
+func (d *Durable) syncLocked() error {
+	if d.segment == nil || !d.unsynced {
+		return nil
+	}
+	if err := d.segment.Sync(); err != nil {
+		return d.fail(err)
+	}
+	d.unsynced = false
+	return nil
+}
+
+// Snapshot writes the whole state to the snapshot file and removes the log
+// segments it covers. Writes wait only while the state is copied and a new
+// segment started, not while the snapshot is written.
+func (d *Durable) Snapshot() error {
+	d.snapshotMu.Lock()
+	defer d.snapshotMu.Unlock()
+
+	d.mu.Lock()
+	if d.segment == nil {
+		d.mu.Unlock()
+		return errClosed
+	}
+	users, _ := d.mem.List(context.Background())
+	snap := snapshot{LSN: d.lsn, Seq: d.mem.nextID() - 1, Users: users}
+	err := d.rotate()
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(d.dir, snapshotFile), data); err != nil {
+		return err
+	}
+
+	// every segment before the one started by rotate is in the snapshot
+	segments, err := listSegments(d.dir)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if seg.firstLSN <= snap.LSN {
+			if err := os.Remove(seg.path); err != nil {
+				return err
+			}
+		}
+	}
+	return syncDir(d.dir)
+}
+
+// rotate closes the current segment and starts a new one for the records
+// after d.lsn. d.mu must be held.
+func (d *Durable) rotate() error {
+	if err := d.segment.Sync(); err != nil {
+		return d.fail(err)
+	}
+	if err := d.segment.Close(); err != nil {
+		d.segment = nil
+		return err
+	}
+	d.unsynced = false
+
+	f, err := os.OpenFile(filepath.Join(d.dir, segmentName(d.lsn+1)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		d.segment = nil
+		return err
+	}
+	d.segment = f
+	return syncDir(d.dir)
+}
+
+func (d *Durable) background() {
+	defer close(d.done)
+
+	var syncTick, snapshotTick <-chan time.Time
+	if d.opts.Sync == SyncInterval {
+		t := time.NewTicker(d.opts.SyncInterval)
+		defer t.Stop()
+		syncTick = t.C
+	}
+	if d.opts.SnapshotInterval > 0 {
+		t := time.NewTicker(d.opts.SnapshotInterval)
+		defer t.Stop()
+		snapshotTick = t.C
+	}
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-syncTick:
+			if err := d.Sync(); err != nil {
+				log.Printf("store: syncing the write-ahead log failed: %v", err)
+			}
+		case <-snapshotTick:
+			if err := d.Snapshot(); err != nil {
+				log.Printf("store: snapshot failed: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops the background work and flushes and closes the log. Calling
+// it again returns the first call's result.
+func (d *Durable) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+		d.closeErr = d.closeLog()
+	})
+	return d.closeErr
+}
+
+func (d *Durable) closeLog() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.segment == nil {
+		return nil
+	}
+	err := d.syncLocked()
+	if d.segment != nil {
+		err = errors.Join(err, d.segment.Close())
+		d.segment = nil
+	}
+	return err
+}
@@ -0,0 +1,306 @@
+package store_test
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"echo-2/store"
+	"echo-2/store/storetest"
+)
+
+func TestDurable(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.UserStore {
+		return openDurable(t, t.TempDir())
+	})
+}
+
+// openDurable opens the store in dir and closes it when the test ends,
+// tests that reopen dir close it themselves first.
+func openDurable(t *testing.T, dir string) *store.Durable {
+	t.Helper()
+	d, _, err := store.OpenDurable(dir, store.DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func reopen(t *testing.T, dir string) (*store.Durable, store.Recovery) {
+	t.Helper()
+	d, rec, err := store.OpenDurable(dir, store.DurableOptions{})
+	if err != nil {
+		t.Fatalf("OpenDurable after a crash: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, rec
+}
+
+func mustCreate(t *testing.T, s store.UserStore, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if _, err := s.Create(context.Background(), name); err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+	}
+}
+
+func mustClose(t *testing.T, d *store.Durable) {
+	t.Helper()
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func wantUsers(t *testing.T, s store.UserStore, want ...store.User) {
+	t.Helper()
+	got, err := s.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("users = %+v, want %+v", got, want)
+	}
+}
+
+// onlySegment returns the path of the single log segment in dir.
+func onlySegment(t *testing.T, dir string) string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("log segments = %v, %v, want exactly one", paths, err)
+	}
+	return paths[0]
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestDurableReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := openDurable(t, dir)
+	mustCreate(t, d, "ada", "bob", "cy")
+	if _, err := d.Update(ctx, 1, "ada lovelace"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	mustClose(t, d)
+
+	d, rec := reopen(t, dir)
+	if rec.Replayed != 5 || rec.TornBytes != 0 {
+		t.Errorf("recovery = %+v, want 5 records replayed and nothing torn", rec)
+	}
+	wantUsers(t, d, store.User{ID: 1, Name: "ada lovelace"}, store.User{ID: 3, Name: "cy"})
+
+	// ids aren't reused after a restart either
+	u, err := d.Create(ctx, "dee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 4 {
+		t.Errorf("ID after reopen = %d, want 4", u.ID)
+	}
+}
+
+func TestDurableCloseTwice(t *testing.T) {
+	d := openDurable(t, t.TempDir())
+	mustClose(t, d)
+	if err := d.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := d.Create(context.Background(), "ada"); err == nil {
+		t.Error("Create after Close succeeded")
+	}
+}
+
+// TestDurableTornAppend cuts the last record short, as a crash in the
+// middle of its write would. Recovery drops it and the log stays usable.
+func TestDurableTornAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		// keep is how many bytes of the last record survive
+		keep int64
+		// zeros are appended after them
+		zeros int
+	}{
+		{"HalfHeader", 3, 0},
+		{"HalfPayload", 12, 0},
+		{"HalfPayloadThenZeros", 12, 4096},
+		{"ZeroFilledTail", 0, 4096},
+		{"ZeroFilledRecord", 0, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			d := openDurable(t, dir)
+			mustCreate(t, d, "ada", "bob")
+			path := onlySegment(t, dir)
+			good := fileSize(t, path)
+			mustCreate(t, d, "cy")
+			mustClose(t, d)
+
+			if err := os.Truncate(path, good+tt.keep); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.Write(make([]byte, tt.zeros)); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			d, rec := reopen(t, dir)
+			if want := tt.keep + int64(tt.zeros); rec.TornBytes != want {
+				t.Errorf("TornBytes = %d, want %d", rec.TornBytes, want)
+			}
+			if size := fileSize(t, path); size != good {
+				t.Errorf("log is %d bytes after recovery, want it cut back to %d", size, good)
+			}
+			wantUsers(t, d, store.User{ID: 1, Name: "ada"}, store.User{ID: 2, Name: "bob"})
+
+			// the next record goes where the torn one was and is read back
+			mustCreate(t, d, "dee")
+			mustClose(t, d)
+			d, rec = reopen(t, dir)
+			if rec.TornBytes != 0 {
+				t.Errorf("TornBytes after a clean close = %d", rec.TornBytes)
+			}
+			wantUsers(t, d, store.User{ID: 1, Name: "ada"}, store.User{ID: 2, Name: "bob"}, store.User{ID: 3, Name: "dee"})
+		})
+	}
+}
+
+// TestDurableCorruption damages a record that has valid records after it,
+// which no crash can do. Open must refuse instead of dropping the rest of
+// the log.
+func TestDurableCorruption(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(data []byte)
+	}{
+		{"Payload", func(data []byte) { data[10] ^= 0xff }},
+		{"Checksum", func(data []byte) { data[4] ^= 0xff }},
+		{"HugeLength", func(data []byte) { binary.LittleEndian.PutUint32(data[0:4], 1<<31) }},
+		{"ZeroLength", func(data []byte) { binary.LittleEndian.PutUint32(data[0:4], 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			d := openDurable(t, dir)
+			mustCreate(t, d, "ada", "bob", "cy")
+			mustClose(t, d)
+
+			path := onlySegment(t, dir)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(data)
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if d, _, err := store.OpenDurable(dir, store.DurableOptions{}); err == nil {
+				d.Close()
+				t.Fatal("OpenDurable of a corrupt log succeeded")
+			}
+			if size := fileSize(t, path); size != int64(len(data)) {
+				t.Errorf("log is %d bytes, want it left alone at %d", size, len(data))
+			}
+		})
+	}
+}
+
+// TestDurableCrashAfterSnapshot leaves the segments a snapshot covers in
+// place, as a crash between writing the snapshot and removing them does.
+// Their records must not be applied a second time.
+func TestDurableCrashAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	d := openDurable(t, dir)
+	mustCreate(t, d, "ada", "bob", "cy")
+	if err := d.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Update(ctx, 3, "cy young"); err != nil {
+		t.Fatal(err)
+	}
+
+	// keep a copy of the log the snapshot is about to remove
+	old := onlySegment(t, dir)
+	data, err := os.ReadFile(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("segment covered by the snapshot still exists: %v", err)
+	}
+	mustCreate(t, d, "dee")
+	mustClose(t, d)
+
+	if err := os.WriteFile(old, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, rec := reopen(t, dir)
+	if rec.SnapshotLSN != 5 || rec.Replayed != 1 {
+		t.Errorf("recovery = %+v, want snapshot at 5 and 1 record replayed", rec)
+	}
+	wantUsers(t, d,
+		store.User{ID: 1, Name: "ada"},
+		store.User{ID: 3, Name: "cy young"},
+		store.User{ID: 4, Name: "dee"},
+	)
+}
+
+// TestDurableGap loses a snapshot after it replaced the first segment, the
+// records after it can't be applied without the ones it held.
+func TestDurableGap(t *testing.T) {
+	dir := t.TempDir()
+
+	d := openDurable(t, dir)
+	mustCreate(t, d, "ada")
+	first := onlySegment(t, dir)
+	if err := d.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, d, "bob")
+	mustClose(t, d)
+
+	if err := os.Remove(filepath.Join(dir, "snapshot.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("first segment still exists: %v", err)
+	}
+
+	if d, _, err := store.OpenDurable(dir, store.DurableOptions{}); err == nil {
+		d.Close()
+		t.Fatal("OpenDurable of a log with a gap succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Memory keeps users in a map, they are gone when the process exits.
+type Memory struct {
+	mu    sync.Mutex
+	users map[int]User
+	seq   int
+}
+
+func NewMemory() *Memory {
+	return &Memory{users: map[int]User{}}
+}
+
+func (m *Memory) Create(ctx context.Context, name string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+	u := User{ID: m.seq, Name: name}
+	m.users[u.ID] = u
+	return u, nil
+}
+
+func (m *Memory) Get(ctx context.Context, id int) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *Memory) List(ctx context.Context) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b User) int { return a.ID - b.ID })
+	return users, nil
+}
+
+func (m *Memory) Update(ctx context.Context, id int, name string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	u.Name = name
+	m.users[id] = u
+	return u, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// put stores u as is, for replaying a log. Later ids continue after u.ID.
+func (m *Memory) put(u User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users[u.ID] = u
+	m.seq = max(m.seq, u.ID)
+}
+
+// nextID is the id the next Create assigns.
+func (m *Memory) nextID() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.seq + 1
+}
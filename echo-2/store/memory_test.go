@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"echo-2/store"
+	"echo-2/store/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.UserStore {
+		return store.NewMemory()
+	})
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Mongo keeps users in the users collection of db, with their id as _id.
+// Ids come from a counter document in the counters collection, so they are
+// the same small increasing integers as with the other stores.
+type Mongo struct {
+	users    *mongo.Collection
+	counters *mongo.Collection
+}
+
+func NewMongo(db *mongo.Database) *Mongo {
+	return &Mongo{users: db.Collection("users"), counters: db.Collection("counters")}
+}
+
+type mongoUser struct {
+	ID   int    `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+func (m *Mongo) nextID(ctx context.Context) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": "users"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Seq, err
+}
+
+func (m *Mongo) Create(ctx context.Context, name string) (User, error) {
+	id, err := m.nextID(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	if _, err := m.users.InsertOne(ctx, mongoUser{ID: id, Name: name}); err != nil {
+		return User{}, err
+	}
+	return User{ID: id, Name: name}, nil
+}
+
+func (m *Mongo) Get(ctx context.Context, id int) (User, error) {
+	var u mongoUser
+	err := m.users.FindOne(ctx, bson.M{"_id": id}).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return User(u), err
+}
+
+func (m *Mongo) List(ctx context.Context) ([]User, error) {
+	cursor, err := m.users.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []mongoUser
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, len(docs))
+	for i, u := range docs {
+		users[i] = User(u)
+	}
+	return users, nil
+}
+
+func (m *Mongo) Update(ctx context.Context, id int, name string) (User, error) {
+	var u mongoUser
+	err := m.users.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"name": name}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return User(u), err
+}
+
+func (m *Mongo) Delete(ctx context.Context, id int) error {
+	res, err := m.users.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
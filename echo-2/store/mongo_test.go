@@ -0,0 +1,51 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"echo-2/store"
+	"echo-2/store/storetest"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// TestMongo needs a server it may write to, e.g.
+// MONGODB_URI=mongodb://localhost:27017 go test ./store. It uses the
+// echo_store_test database and drops it afterwards.
+func TestMongo(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database("echo_store_test")
+	t.Cleanup(func() { db.Drop(context.Background()) })
+
+	storetest.Run(t, func(t *testing.T) store.UserStore {
+		// the counters collection holds the id sequence, dropping it starts
+		// the ids at 1 again
+		for _, name := range []string{"users", "counters"} {
+			if err := db.Collection(name).Drop(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return store.NewMongo(db)
+	})
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres keeps users in the echo_users table, which it creates when it's
+// missing.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgres(ctx context.Context, pool *pgxpool.Pool) (*Postgres, error) {
+	_, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS echo_users (
+		id   serial PRIMARY KEY,
+		name text NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{pool: pool}, nil
+}
+
+func (p *Postgres) Create(ctx context.Context, name string) (User, error) {
+	var u User
+	err := p.pool.QueryRow(ctx, "INSERT INTO echo_users (name) VALUES ($1) RETURNING id, name", name).Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+func (p *Postgres) Get(ctx context.Context, id int) (User, error) {
+	var u User
+	err := p.pool.QueryRow(ctx, "SELECT id, name FROM echo_users WHERE id = $1", id).Scan(&u.ID, &u.Name)
+	return u, notFound(err)
+}
+
+func (p *Postgres) List(ctx context.Context) ([]User, error) {
+	rows, err := p.pool.Query(ctx, "SELECT id, name FROM echo_users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[User])
+}
+
+func (p *Postgres) Update(ctx context.Context, id int, name string) (User, error) {
+	var u User
+	err := p.pool.QueryRow(ctx, "UPDATE echo_users SET name = $2 WHERE id = $1 RETURNING id, name", id, name).Scan(&u.ID, &u.Name)
+	return u, notFound(err)
+}
+
+func (p *Postgres) Delete(ctx context.Context, id int) error {
+	tag, err := p.pool.Exec(ctx, "DELETE FROM echo_users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
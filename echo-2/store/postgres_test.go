@@ -0,0 +1,39 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"echo-2/store"
+	"echo-2/store/storetest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestPostgres needs a database it may wipe, e.g.
+// DATABASE_URL=postgres://localhost/echo_test go test ./store
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	storetest.Run(t, func(t *testing.T) store.UserStore {
+		s, err := store.NewPostgres(ctx, pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, "TRUNCATE echo_users RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
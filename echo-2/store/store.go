@@ -0,0 +1,34 @@
+// Package store keeps the users behind the UserStore interface, with an
+// in-memory, a Postgres and a Mongo implementation.
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("user not found")
+
+type User struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserStore is where the handlers keep users. IDs are assigned by the store,
+// start at 1 and are never reused.
+type UserStore interface {
+	Create(ctx context.Context, name string) (User, error)
+	// Get returns ErrNotFound for unknown ids, as do Update and Delete.
+	Get(ctx context.Context, id int) (User, error)
+	// List returns every user ordered by id.
+	List(ctx context.Context) ([]User, error)
+	Update(ctx context.Context, id int, name string) (User, error)
+	Delete(ctx context.Context, id int) error
+}
+
+var (
+	_ UserStore = (*Memory)(nil)
+	_ UserStore = (*Postgres)(nil)
+	_ UserStore = (*Mongo)(nil)
+	_ UserStore = (*Durable)(nil)
+)
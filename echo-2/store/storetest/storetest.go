@@ -0,0 +1,199 @@
+// Package storetest is the behavioral test suite every store.UserStore has
+// to pass. Call Run from the tests of an implementation:
+//
+//	func TestMemory(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.UserStore {
+//			return store.NewMemory()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"echo-2/store"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// Run runs the suite. newStore is called once per subtest and must return
+// an empty store that hasn't assigned any ids yet, for a database that means
+// dropping the data and resetting the id sequence.
+func Run(t *testing.T, newStore func(t *testing.T) store.UserStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.UserStore)
+	}{
+		{"CreateAssignsIncreasingIDs", testCreateAssignsIncreasingIDs},
+		{"GetReturnsCreated", testGetReturnsCreated},
+		{"MissingUser", testMissingUser},
+		{"ListOrderedByID", testListOrderedByID},
+		{"Update", testUpdate},
+		{"DeleteDoesNotReuseIDs", testDeleteDoesNotReuseIDs},
+		{"ConcurrentCreates", testConcurrentCreates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func create(t *testing.T, s store.UserStore, name string) store.User {
+	t.Helper()
+	u, err := s.Create(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Create(%q): %v", name, err)
+	}
+	return u
+}
+
+func testCreateAssignsIncreasingIDs(t *testing.T, s store.UserStore) {
+	a := create(t, s, "ada")
+	b := create(t, s, "bob")
+
+	if a.ID != 1 {
+		t.Errorf("first ID = %d, want 1", a.ID)
+	}
+	if b.ID <= a.ID {
+		t.Errorf("second ID %d is not after first ID %d", b.ID, a.ID)
+	}
+	if a.Name != "ada" || b.Name != "bob" {
+		t.Errorf("names = %q, %q, want ada, bob", a.Name, b.Name)
+	}
+}
+
+func testGetReturnsCreated(t *testing.T, s store.UserStore) {
+	want := create(t, s, "ada")
+
+	got, err := s.Get(context.Background(), want.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != want {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+}
+
+func testMissingUser(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, 42); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Update(ctx, 42, "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Update: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, 42); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testListOrderedByID(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+
+	users, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != 0 {
+		t.Fatalf("List of an empty store = %+v", users)
+	}
+
+	var want []store.User
+	for _, name := range []string{"ada", "bob", "cy"} {
+		want = append(want, create(t, s, name))
+	}
+
+	users, err = s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != len(want) {
+		t.Fatalf("List = %+v, want %+v", users, want)
+	}
+	for i := range want {
+		if users[i] != want[i] {
+			t.Errorf("List()[%d] = %+v, want %+v", i, users[i], want[i])
+		}
+	}
+}
+
+func testUpdate(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := create(t, s, "ada")
+
+	updated, err := s.Update(ctx, u.ID, "ada lovelace")
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated != (store.User{ID: u.ID, Name: "ada lovelace"}) {
+		t.Errorf("Update = %+v", updated)
+	}
+
+	got, err := s.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != updated {
+		t.Errorf("Get after Update = %+v, want %+v", got, updated)
+	}
+}
+
+func testDeleteDoesNotReuseIDs(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	a := create(t, s, "ada")
+	b := create(t, s, "bob")
+
+	if err := s.Delete(ctx, b.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, b.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, b.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete: err = %v, want ErrNotFound", err)
+	}
+
+	c := create(t, s, "cy")
+	if c.ID == a.ID || c.ID == b.ID {
+		t.Errorf("ID %d was used before", c.ID)
+	}
+
+	users, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != 2 || users[0] != a || users[1] != c {
+		t.Errorf("List = %+v, want %+v, %+v", users, a, c)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, s store.UserStore) {
+	const n = 50
+
+	ids := make(chan int, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := s.Create(context.Background(), "user")
+			if err != nil {
+				t.Errorf("Create: %v", err)
+				return
+			}
+			ids <- u.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("ID %d was assigned twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A log segment is a sequence of records, each
+//
+//	length  uint32, little endian, of the payload
+//	crc     uint32, CRC-32C of the payload
+//	payload JSON encoded walRecord
+//
+// Segments are named after the LSN of their first record, so the ones
+// covered by a snapshot can be found without reading them.
+
+const (
+	walHeaderSize = 8
+	// maxRecordSize bounds the length read from a header, a garbage length
+	// in a torn header must not make us allocate gigabytes.
+	maxRecordSize = 1 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type walOp string
+
+const (
+	opCreate walOp = "create"
+	opUpdate walOp = "update"
+	opDelete walOp = "delete"
+)
+
+type walRecord struct {
+	LSN  uint64 `json:"lsn"`
+	Op   walOp  `json:"op"`
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+func encodeRecord(rec walRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	return append(buf, payload...), nil
+}
+
+// errTorn means a segment ends in an incomplete record, which is what a
+// crash in the middle of a write leaves behind.
+var errTorn = errors.New("torn record")
+
+// readSegment decodes every record of the segment at path. It returns the
+// records up to the first bad one and the offset where that bad record
+// starts. A bad record is torn, and errTorn returned, when it is the write
+// a crash interrupted: its header is incomplete, its payload runs past the
+// end of the file, or nothing but zeros follow it (file systems can extend a
+// file before the data lands, leaving a zero-filled tail). Any other bad
+// record is corruption, reported as such.
+func readSegment(path string) ([]walRecord, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []walRecord
+	var off int64
+	for int(off) < len(data) {
+		rest := data[off:]
+		if len(rest) < walHeaderSize || allZero(rest) {
+			return records, off, errTorn
+		}
+		length := binary.LittleEndian.Uint32(rest[0:4])
+		sum := binary.LittleEndian.Uint32(rest[4:8])
+		if length == 0 || length > maxRecordSize {
+			return records, off, fmt.Errorf("corrupt record at offset %d: length %d", off, length)
+		}
+		end := walHeaderSize + int64(length)
+		if end > int64(len(rest)) {
+			return records, off, errTorn
+		}
+
+		payload := rest[walHeaderSize:end]
+		var rec walRecord
+		if crc32.Checksum(payload, crcTable) != sum || json.Unmarshal(payload, &rec) != nil {
+			if allZero(rest[end:]) {
+				return records, off, errTorn
+			}
+			return records, off, fmt.Errorf("corrupt record at offset %d", off)
+		}
+
+		records = append(records, rec)
+		off += end
+	}
+	return records, off, nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func segmentName(firstLSN uint64) string {
+	return fmt.Sprintf("wal-%020d.log", firstLSN)
+}
+
+type segment struct {
+	path     string
+	firstLSN uint64
+}
+
+// listSegments returns the segments in dir, oldest first.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "wal-") || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		lsn, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "wal-"), ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(dir, name), firstLSN: lsn})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].firstLSN < segments[j].firstLSN })
+	return segments, nil
+}
+
+// writeFileAtomic replaces path with data so that after a crash path holds
+// either the old or the new content, never a mix.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes renames, creations and removals in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"myapp/users"
+	"os"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type command func(ctx context.Context, store *users.Store, out printer, args []string) error
+
+var commands = map[string]command{
+	"add":    addUser,
+	"get":    getUser,
+	"list":   listUsers,
+	"update": updateUser,
+	"delete": deleteUser,
+	"import": importUsers,
+}
+
+func addUser(ctx context.Context, store *users.Store, out printer, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	name := fs.String("name", "", "user name")
+	age := fs.Int("age", 0, "user age")
+	fs.Parse(args)
+
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+	if *age < 0 {
+		return errors.New("-age can't be negative")
+	}
+
+	u, err := store.Add(ctx, users.User{Name: *name, Age: *age})
+	if err != nil {
+		return err
+	}
+	return out.user(u)
+}
+
+func getUser(ctx context.Context, store *users.Store, out printer, args []string) error {
+	id, err := idArg(args)
+	if err != nil {
+		return err
+	}
+
+	u, err := store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return out.user(u)
+}
+
+func listUsers(ctx context.Context, store *users.Store, out printer, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	name := fs.String("name", "", "only users whose name starts with this prefix")
+	limit := fs.Int64("limit", 0, "maximum number of users, 0 for all")
+	fs.Parse(args)
+
+	list, err := store.List(ctx, *name, *limit)
+	if err != nil {
+		return err
+	}
+	return out.users(list)
+}
+
+func updateUser(ctx context.Context, store *users.Store, out printer, args []string) error {
+	id, err := idArg(args)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	name := fs.String("name", "", "new name")
+	age := fs.Int("age", 0, "new age")
+	fs.Parse(args[1:])
+
+	// only change what was passed, so -age 0 still means "set age to 0"
+	var update users.Update
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			update.Name = name
+		case "age":
+			update.Age = age
+		}
+	})
+	if update.Name == nil && update.Age == nil {
+		return errors.New("nothing to update, pass -name and/or -age")
+	}
+	if update.Name != nil && *update.Name == "" {
+		return errors.New("-name can't be empty")
+	}
+	if update.Age != nil && *update.Age < 0 {
+		return errors.New("-age can't be negative")
+	}
+
+	u, err := store.Update(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	return out.user(u)
+}
+
+func deleteUser(ctx context.Context, store *users.Store, out printer, args []string) error {
+	id, err := idArg(args)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Delete(ctx, id); err != nil {
+		return err
+	}
+	return out.message(fmt.Sprintf("deleted %s", id.Hex()))
+}
+
+func importUsers(ctx context.Context, store *users.Store, out printer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a file name, or - for stdin")
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	list, err := readUsers(r)
+	if err != nil {
+		return err
+	}
+	for i, u := range list {
+		if u.Name == "" {
+			return fmt.Errorf("user %d has no name", i+1)
+		}
+	}
+
+	n, err := store.Import(ctx, list)
+	if err != nil {
+		return fmt.Errorf("imported %d of %d users: %w", n, len(list), err)
+	}
+	return out.message(fmt.Sprintf("imported %d users", n))
+}
+
+// readUsers accepts either a JSON array or newline delimited JSON objects.
+func readUsers(r io.Reader) ([]users.User, error) {
+	br := bufio.NewReader(r)
+
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []users.User
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		if err := dec.Decode(&list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+
+	for {
+		var u users.User
+		err := dec.Decode(&u)
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(list)+1, err)
+		}
+		list = append(list, u)
+	}
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+func idArg(args []string) (bson.ObjectID, error) {
+	if len(args) == 0 {
+		return bson.ObjectID{}, errors.New("expected a user id")
+	}
+
+	id, err := bson.ObjectIDFromHex(args[0])
+	if err != nil {
+		return bson.ObjectID{}, fmt.Errorf("invalid user id %q", args[0])
+	}
+	return id, nil
+}
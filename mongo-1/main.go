@@ -2,17 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"myapp/config"
+	"myapp/users"
+	"os"
 	"time"
 
-	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
+const usage = `usage: users [flags] <command> [args]
+
+commands:
+  add     -name NAME [-age N]          add a user
+  get     ID                           show one user
+  list    [-name PREFIX] [-limit N]    list users sorted by name
+  update  ID [-name NAME] [-age N]     change a user
+  delete  ID                           delete a user
+  import  FILE                         insert users from a JSON array or
+                                       NDJSON file, "-" reads stdin
+
+flags:
+`
+
 func connectMongoDB(uri string) (*mongo.Client, error) {
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
 	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
@@ -34,46 +51,69 @@ func connectMongoDB(uri string) (*mongo.Client, error) {
 }
 
 func main() {
+	log.SetFlags(0)
+
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
 	cfg := config.LoadConfig()
 
-	client, err := connectMongoDB(cfg.MongoURI)
-	if err != nil {
-		log.Fatal("Error connecting to MongoDB:", err)
+	// settings from .env / the environment are the defaults, flags win
+	fs := flag.NewFlagSet("users", flag.ExitOnError)
+	mongoURI := fs.String("mongo-uri", cfg.MongoURI, "MongoDB connection string (MONGO_URI)")
+	database := fs.String("db", "go-mongo-1", "database name")
+	collectionName := fs.String("collection", "users", "collection name")
+	output := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for the whole command")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
 	}
-	defer func() {
-		if err := client.Disconnect(context.Background()); err != nil {
-			log.Fatal("Error disconnecting MongoDB:", err)
-		}
-	}()
+	fs.Parse(args)
 
-	db := client.Database("go-mongo-1")
-	collection := db.Collection("users")
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(fs.Output(), "unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
 
-	doc := bson.M{"name": "Alice", "age": 30}
-	result, err := collection.InsertOne(context.Background(), doc)
+	printer, err := newPrinter(*output, os.Stdout)
 	if err != nil {
-		log.Fatal("Error inserting document:", err)
+		return err
 	}
-	fmt.Println("Inserted document ID:", result.InsertedID)
 
-	filter := bson.M{"name": "Alice"}
-	var result2 bson.M
-	err = collection.FindOne(context.Background(), filter).Decode(&result)
+	if *mongoURI == "" {
+		return errors.New("no MongoDB URI, set MONGO_URI or pass -mongo-uri")
+	}
+
+	client, err := connectMongoDB(*mongoURI)
 	if err != nil {
-		log.Fatal("Error finding document:", err)
+		return fmt.Errorf("connecting to MongoDB: %w", err)
 	}
-	fmt.Println("Found document:", result2)
+	defer func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			log.Println("Error disconnecting MongoDB:", err)
+		}
+	}()
 
-	// server := &http.Server{
-	// 	Addr:    ":" + cfg.AppPort,
-	// 	Handler: nil,
-	// }
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
 
-	// fmt.Println("Starting server on port", cfg.AppPort)
-	// if err := server.ListenAndServe(); err != nil {
-	// 	log.Fatal("failed to start server: ", err)
-	// }
+	store := users.NewStore(client.Database(*database).Collection(*collectionName))
 
+	if err := cmd(ctx, store, printer, fs.Args()[1:]); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return nil
 }
 
 /*
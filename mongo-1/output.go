@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"myapp/users"
+	"text/tabwriter"
+)
+
+type printer interface {
+	user(u users.User) error
+	users(list []users.User) error
+	message(msg string) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return tablePrinter{w: w}, nil
+	case "json":
+		return jsonPrinter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p tablePrinter) user(u users.User) error {
+	return p.users([]users.User{u})
+}
+
+func (p tablePrinter) users(list []users.User) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tAGE")
+	for _, u := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", u.ID.Hex(), u.Name, u.Age)
+	}
+	return tw.Flush()
+}
+
+func (p tablePrinter) message(msg string) error {
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p jsonPrinter) user(u users.User) error {
+	return p.encode(u)
+}
+
+func (p jsonPrinter) users(list []users.User) error {
+	return p.encode(list)
+}
+
+func (p jsonPrinter) message(msg string) error {
+	return p.encode(map[string]string{"message": msg})
+}
+
+func (p jsonPrinter) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,136 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrNotFound = errors.New("user not found")
+
+type User struct {
+	ID   bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name string        `bson:"name" json:"name"`
+	Age  int           `bson:"age" json:"age"`
+}
+
+// Update holds the fields to change; nil fields are left alone.
+type Update struct {
+	Name *string
+	Age  *int
+}
+
+type Store struct {
+	collection *mongo.Collection
+}
+
+func NewStore(collection *mongo.Collection) *Store {
+	return &Store{collection: collection}
+}
+
+func (s *Store) Add(ctx context.Context, u User) (User, error) {
+	u.ID = bson.NewObjectID()
+	if _, err := s.collection.InsertOne(ctx, u); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (s *Store) Get(ctx context.Context, id bson.ObjectID) (User, error) {
+	var u User
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+// List returns users sorted by name. namePrefix filters case insensitively
+// and limit <= 0 means no limit.
+func (s *Store) List(ctx context.Context, namePrefix string, limit int64) ([]User, error) {
+	filter := bson.M{}
+	if namePrefix != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(namePrefix), "$options": "i"}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *Store) Update(ctx context.Context, id bson.ObjectID, update Update) (User, error) {
+	set := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Age != nil {
+		set["age"] = *update.Age
+	}
+	if len(set) == 0 {
+		return s.Get(ctx, id)
+	}
+
+	var u User
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (s *Store) Delete(ctx context.Context, id bson.ObjectID) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Import inserts users in one unordered batch, so a bad document doesn't stop
+// the rest from being written. It returns how many were inserted.
+func (s *Store) Import(ctx context.Context, users []User) (int, error) {
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	docs := make([]any, len(users))
+	for i, u := range users {
+		if u.ID.IsZero() {
+			u.ID = bson.NewObjectID()
+		}
+		docs[i] = u
+	}
+
+	_, err := s.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+
+	// InsertedIDs lists every id that was sent, failed or not, so count the
+	// write errors instead
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		return len(docs) - len(bulkErr.WriteErrors), err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
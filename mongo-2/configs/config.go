@@ -2,7 +2,9 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -10,6 +12,12 @@ import (
 type Config struct {
 	AppPort  string
 	MongoURI string
+
+	// per operation database timeouts, e.g. DB_READ_TIMEOUT=5s. Left at zero
+	// when unset so the handlers fall back to their defaults.
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	UploadTimeout time.Duration
 }
 
 func LoadConfig() (Config, error) {
@@ -17,8 +25,28 @@ func LoadConfig() (Config, error) {
 		return Config{}, errors.New("failed to load .env file")
 	}
 
-	return Config{
+	cfg := Config{
 		AppPort:  os.Getenv("APP_PORT"),
 		MongoURI: os.Getenv("MONGO_URI"),
-	}, nil
+	}
+
+	durations := map[string]*time.Duration{
+		"DB_READ_TIMEOUT":   &cfg.ReadTimeout,
+		"DB_WRITE_TIMEOUT":  &cfg.WriteTimeout,
+		"DB_UPLOAD_TIMEOUT": &cfg.UploadTimeout,
+	}
+	for key, dst := range durations {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		*dst = d
+	}
+
+	return cfg, nil
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// StatusClientClosedRequest is the non-standard status nginx uses when the
+// client goes away before the response is written.
+const StatusClientClosedRequest = 499
+
+// Timeouts caps how long a single handler may spend on database work. Zero
+// values fall back to DefaultTimeouts.
+type Timeouts struct {
+	Read   time.Duration
+	Write  time.Duration
+	Upload time.Duration
+}
+
+var DefaultTimeouts = Timeouts{
+	Read:   10 * time.Second,
+	Write:  10 * time.Second,
+	Upload: 30 * time.Second,
+}
+
+// requestContext derives the context for database work from the request, so
+// the work stops as soon as the client disconnects, and bounds it with d.
+func requestContext(c echo.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request().Context(), d)
+}
+
+func (t Timeouts) read() time.Duration {
+	return orDefault(t.Read, DefaultTimeouts.Read)
+}
+
+func (t Timeouts) write() time.Duration {
+	return orDefault(t.Write, DefaultTimeouts.Write)
+}
+
+func (t Timeouts) upload() time.Duration {
+	return orDefault(t.Upload, DefaultTimeouts.Upload)
+}
+
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// dbError turns an error from the driver into a response. Deadlines become
+// 504 and cancellations (the client hung up) become 499; anything else is a
+// plain 500.
+func dbError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return c.JSON(StatusClientClosedRequest, echo.Map{"error": "request cancelled"})
+	case errors.Is(err, context.DeadlineExceeded), mongo.IsTimeout(err):
+		return c.JSON(http.StatusGatewayTimeout, echo.Map{"error": "database operation timed out"})
+	default:
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+}
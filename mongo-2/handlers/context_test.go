@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func product(name string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: bson.NewObjectID()},
+		{Key: "name", Value: name},
+		{Key: "price", Value: 1.5},
+		{Key: "stock", Value: 3},
+	}
+}
+
+// slowCursor serves a first batch of one product and then blocks every
+// getMore until the server shuts down, announcing each on getMores.
+func slowCursor(getMores chan<- struct{}) func(ctx context.Context, cmd string, doc bson.Raw) bson.D {
+	return func(ctx context.Context, cmd string, doc bson.Raw) bson.D {
+		switch cmd {
+		case "find":
+			return cursorReply("firstBatch", 42, product("first"))
+		case "getMore":
+			getMores <- struct{}{}
+			<-ctx.Done()
+			return nil
+		}
+		return bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: "unexpected " + cmd}}
+	}
+}
+
+func getProducts(t *testing.T, h *ProductHandler, ctx context.Context) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	if err := h.GetProducts(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+	return rec
+}
+
+func TestGetProductsCancelledDuringIteration(t *testing.T) {
+	getMores := make(chan struct{}, 10)
+	server := newFakeMongo(t, slowCursor(getMores))
+	h := &ProductHandler{Collection: server.client().Database("api_test").Collection("products")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// the client hangs up while the handler waits for the second batch
+		<-getMores
+		cancel()
+	}()
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- getProducts(t, h, ctx) }()
+
+	var rec *httptest.ResponseRecorder
+	select {
+	case rec = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetProducts kept waiting on the cursor after the request was cancelled")
+	}
+
+	if rec.Code != StatusClientClosedRequest {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, StatusClientClosedRequest, rec.Body)
+	}
+
+	// the cursor must not ask for more batches once the request is gone
+	time.Sleep(100 * time.Millisecond)
+	if n := len(getMores); n != 0 {
+		t.Errorf("%d more getMore commands after cancelling", n)
+	}
+	if got := server.seen(); !slices.Equal(got, []string{"find", "getMore"}) {
+		t.Errorf("commands = %v, want [find getMore]", got)
+	}
+}
+
+func TestGetProductsTimesOutDuringIteration(t *testing.T) {
+	getMores := make(chan struct{}, 10)
+	server := newFakeMongo(t, slowCursor(getMores))
+	h := &ProductHandler{
+		Collection: server.client().Database("api_test").Collection("products"),
+		Timeouts:   Timeouts{Read: 200 * time.Millisecond},
+	}
+
+	start := time.Now()
+	rec := getProducts(t, h, context.Background())
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, http.StatusGatewayTimeout, rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetProducts took %v with a 200ms read timeout", elapsed)
+	}
+	if len(getMores) != 1 {
+		t.Errorf("%d getMore commands, want 1", len(getMores))
+	}
+}
+
+func TestGetProductsCancelledBeforeFind(t *testing.T) {
+	server := newFakeMongo(t, slowCursor(make(chan struct{}, 10)))
+	h := &ProductHandler{Collection: server.client().Database("api_test").Collection("products")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := getProducts(t, h, ctx)
+
+	if rec.Code != StatusClientClosedRequest {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, StatusClientClosedRequest, rec.Body)
+	}
+	if got := server.seen(); len(got) != 0 {
+		t.Errorf("commands = %v, want none", got)
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// fakeMongo speaks just enough of the wire protocol for the driver to
+// connect and run commands. Handshakes and housekeeping commands are
+// answered here, everything else goes to handle, which may block to
+// simulate a slow server. A nil reply closes the connection.
+type fakeMongo struct {
+	t      *testing.T
+	ln     net.Listener
+	handle func(ctx context.Context, cmd string, doc bson.Raw) bson.D
+
+	mu       sync.Mutex
+	commands []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newFakeMongo(t *testing.T, handle func(ctx context.Context, cmd string, doc bson.Raw) bson.D) *fakeMongo {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &fakeMongo{t: t, ln: ln, handle: handle, ctx: ctx, cancel: cancel}
+
+	f.wg.Add(1)
+	go f.accept()
+	t.Cleanup(func() {
+		cancel()
+		ln.Close()
+		f.wg.Wait()
+	})
+	return f
+}
+
+// client connects to the fake server and disconnects when the test ends.
+func (f *fakeMongo) client() *mongo.Client {
+	f.t.Helper()
+
+	client, err := mongo.Connect(options.Client().
+		ApplyURI("mongodb://" + f.ln.Addr().String() + "/?directConnection=true").
+		SetRetryReads(false).
+		SetRetryWrites(false))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		client.Disconnect(ctx)
+	})
+	return client
+}
+
+// seen returns the commands passed to handle so far, in order.
+func (f *fakeMongo) seen() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func (f *fakeMongo) accept() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			f.serve(conn)
+		}()
+	}
+}
+
+func (f *fakeMongo) serve(conn net.Conn) {
+	defer conn.Close()
+	go func() {
+		<-f.ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.LittleEndian.Uint32(header[0:4]))
+		requestID := binary.LittleEndian.Uint32(header[4:8])
+		opCode := binary.LittleEndian.Uint32(header[12:16])
+		body := make([]byte, length-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		var doc bson.Raw
+		switch opCode {
+		case opQuery:
+			// flags, then the collection name as a cstring, skip and limit
+			i := 4
+			for body[i] != 0 {
+				i++
+			}
+			doc = bson.Raw(body[i+1+8:])
+		case opMsg:
+			// flag bits, then a single kind 0 section holding the command
+			if body[4] != 0 {
+				f.t.Errorf("fake mongo: unsupported OP_MSG section kind %d", body[4])
+				return
+			}
+			doc = bson.Raw(body[5:])
+		default:
+			f.t.Errorf("fake mongo: unsupported op code %d", opCode)
+			return
+		}
+
+		elems, err := doc.Elements()
+		if err != nil || len(elems) == 0 {
+			f.t.Errorf("fake mongo: bad command document: %v", err)
+			return
+		}
+		cmd := elems[0].Key()
+
+		var reply bson.D
+		switch cmd {
+		case "isMaster", "ismaster", "hello":
+			reply = bson.D{
+				{Key: "ismaster", Value: true},
+				{Key: "helloOk", Value: true},
+				{Key: "maxBsonObjectSize", Value: 16 * 1024 * 1024},
+				{Key: "maxMessageSizeBytes", Value: 48000000},
+				{Key: "maxWriteBatchSize", Value: 100000},
+				{Key: "minWireVersion", Value: 0},
+				{Key: "maxWireVersion", Value: 21},
+				{Key: "ok", Value: 1.0},
+			}
+		case "endSessions", "killCursors", "ping":
+			reply = bson.D{{Key: "ok", Value: 1.0}}
+		default:
+			f.mu.Lock()
+			f.commands = append(f.commands, cmd)
+			f.mu.Unlock()
+			reply = f.handle(f.ctx, cmd, doc)
+			if reply == nil {
+				return
+			}
+		}
+
+		if err := writeReply(conn, opCode, requestID, reply); err != nil {
+			return
+		}
+	}
+}
+
+func writeReply(w io.Writer, opCode, responseTo uint32, reply bson.D) error {
+	doc, err := bson.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	switch opCode {
+	case opQuery:
+		// response flags, cursor id, starting from, number returned
+		body = make([]byte, 20)
+		binary.LittleEndian.PutUint32(body[16:20], 1)
+		opCode = opReply
+	case opMsg:
+		// flag bits and the kind of the only section
+		body = make([]byte, 5)
+	default:
+		return errors.New("unsupported op code")
+	}
+	body = append(body, doc...)
+
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(header[8:12], responseTo)
+	binary.LittleEndian.PutUint32(header[12:16], opCode)
+	_, err = w.Write(append(header, body...))
+	return err
+}
+
+func cursorReply(batchKey string, id int64, docs ...any) bson.D {
+	batch := bson.A{}
+	batch = append(batch, docs...)
+	return bson.D{
+		{Key: "cursor", Value: bson.D{
+			{Key: "id", Value: id},
+			{Key: "ns", Value: "api_test.products"},
+			{Key: batchKey, Value: batch},
+		}},
+		{Key: "ok", Value: 1.0},
+	}
+}
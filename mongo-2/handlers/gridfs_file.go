@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// gridFSFile adapts a GridFS file to io.ReadSeeker so it can be handed to
+// http.ServeContent, which needs to seek for range requests. GridFS download
+// streams only read forward, so a seek drops the current stream and the next
+// read reopens it and skips to the requested offset.
+type gridFSFile struct {
+	ctx    context.Context
+	bucket *mongo.GridFSBucket
+	id     any
+	size   int64
+	offset int64
+	stream *mongo.GridFSDownloadStream
+}
+
+func (f *gridFSFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.stream == nil {
+		stream, err := f.bucket.OpenDownloadStream(f.ctx, f.id)
+		if err != nil {
+			return 0, err
+		}
+		if f.offset > 0 {
+			if _, err := stream.Skip(f.offset); err != nil {
+				stream.Close()
+				return 0, err
+			}
+		}
+		f.stream = stream
+	}
+
+	n, err := f.stream.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *gridFSFile) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = f.offset + offset
+	case io.SeekEnd:
+		next = f.size + offset
+	default:
+		return 0, errors.New("gridfs: invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("gridfs: negative position")
+	}
+
+	if next != f.offset && f.stream != nil {
+		f.stream.Close()
+		f.stream = nil
+	}
+	f.offset = next
+
+	return next, nil
+}
+
+func (f *gridFSFile) Close() error {
+	if f.stream == nil {
+		return nil
+	}
+	return f.stream.Close()
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"echo-mongo-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// OrderHandler needs the client as well as the collections, because
+// transactions are started from a client session. Transactions only work
+// against a replica set or sharded cluster, not a standalone mongod.
+type OrderHandler struct {
+	Client   *mongo.Client
+	Products *mongo.Collection
+	Orders   *mongo.Collection
+	Timeouts Timeouts
+}
+
+type checkoutItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+type checkoutRequest struct {
+	Items []checkoutItem `json:"items"`
+}
+
+type productError struct {
+	ProductID bson.ObjectID
+	Reason    string
+}
+
+func (e *productError) Error() string {
+	return fmt.Sprintf("product %s: %s", e.ProductID.Hex(), e.Reason)
+}
+
+const (
+	reasonNotFound   = "not found"
+	reasonOutOfStock = "out of stock"
+)
+
+func (h *OrderHandler) Checkout(c echo.Context) error {
+	ctx, cancel := requestContext(c, h.Timeouts.write())
+	defer cancel()
+
+	var req checkoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if len(req.Items) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "order has no items"})
+	}
+
+	// merge repeated products so each one is decremented exactly once
+	quantities := map[bson.ObjectID]int{}
+	var productIDs []bson.ObjectID
+	for _, item := range req.Items {
+		id, err := bson.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid product id " + item.ProductID})
+		}
+		if item.Quantity <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "quantity must be greater than 0"})
+		}
+		if _, ok := quantities[id]; !ok {
+			productIDs = append(productIDs, id)
+		}
+		quantities[id] += item.Quantity
+	}
+
+	session, err := h.Client.StartSession()
+	if err != nil {
+		return dbError(c, err)
+	}
+	// end the session even if the request was cancelled, so the server
+	// doesn't have to wait for it to time out
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	txnOpts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+
+	// WithTransaction retries the whole callback on TransientTransactionError
+	// and the commit on UnknownTransactionCommitResult, so the callback must
+	// not keep state between attempts.
+	result, err := session.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+		order := models.Order{CreatedAt: time.Now().UTC()}
+
+		for _, id := range productIDs {
+			quantity := quantities[id]
+
+			var product models.Product
+			err := h.Products.FindOneAndUpdate(ctx,
+				bson.M{"_id": id, "stock": bson.M{"$gte": quantity}},
+				bson.M{"$inc": bson.M{"stock": -quantity}},
+			).Decode(&product)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, h.explainMissing(ctx, id)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			order.Items = append(order.Items, models.OrderItem{
+				ProductID: id,
+				Name:      product.Name,
+				Quantity:  quantity,
+				Price:     product.Price,
+			})
+			order.Total += product.Price * float64(quantity)
+		}
+
+		res, err := h.Orders.InsertOne(ctx, order)
+		if err != nil {
+			return nil, err
+		}
+		order.ID = res.InsertedID.(bson.ObjectID)
+
+		return order, nil
+	}, txnOpts)
+
+	var perr *productError
+	if errors.As(err, &perr) {
+		status := http.StatusConflict
+		if perr.Reason == reasonNotFound {
+			status = http.StatusNotFound
+		}
+		return c.JSON(status, echo.Map{"error": perr.Error(), "product_id": perr.ProductID})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+// explainMissing is called when the conditional stock update matched nothing,
+// to tell a missing product apart from one without enough stock.
+func (h *OrderHandler) explainMissing(ctx context.Context, id bson.ObjectID) error {
+	err := h.Products.FindOne(ctx, bson.M{"_id": id}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &productError{ProductID: id, Reason: reasonNotFound}
+	}
+	if err != nil {
+		return err
+	}
+	return &productError{ProductID: id, Reason: reasonOutOfStock}
+}
+
+func (h *OrderHandler) GetOrder(c echo.Context) error {
+	ctx, cancel := requestContext(c, h.Timeouts.read())
+	defer cancel()
+
+	id, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid order id"})
+	}
+
+	var order models.Order
+	if err := h.Orders.FindOne(ctx, bson.M{"_id": id}).Decode(&order); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "order not found"})
+		}
+		return dbError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
@@ -1,11 +1,9 @@
 package handlers
 
 import (
-	"context"
 	"echo-mongo-api/models"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -15,10 +13,12 @@ import (
 
 type ProductHandler struct {
 	Collection *mongo.Collection
+	Bucket     *mongo.GridFSBucket
+	Timeouts   Timeouts
 }
 
 func (h *ProductHandler) CreateProduct(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c, h.Timeouts.write())
 	defer cancel()
 
 	var product models.Product
@@ -41,14 +41,14 @@ func (h *ProductHandler) CreateProduct(c echo.Context) error {
 	result, err := h.Collection.InsertOne(ctx, product)
 	if err != nil {
 		fmt.Println("error inserting product", err)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return dbError(c, err)
 	}
 
 	return c.JSON(http.StatusCreated, echo.Map{"id": result.InsertedID})
 }
 
 func (h *ProductHandler) GetProducts(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := requestContext(c, h.Timeouts.read())
 	defer cancel()
 
 	// projection := bson.M{
@@ -58,7 +58,7 @@ func (h *ProductHandler) GetProducts(c echo.Context) error {
 	cursor, err := h.Collection.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return dbError(c, err)
 	}
 
 	defer cursor.Close(ctx)
@@ -74,11 +74,41 @@ func (h *ProductHandler) GetProducts(c echo.Context) error {
 		if err := cursor.Decode(&product); err != nil {
 			fmt.Println("cursor error ->", cursor)
 			fmt.Println("cursor error description ->", err)
-			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			return dbError(c, err)
 		}
 
 		products = append(products, product)
 	}
+	// Next returns false both at the end of the results and when ctx is
+	// done, so the cursor error has to be checked to tell them apart.
+	if err := cursor.Err(); err != nil {
+		return dbError(c, err)
+	}
 
 	return c.JSON(http.StatusOK, products)
 }
+
+func (h *ProductHandler) DeleteProduct(c echo.Context) error {
+	ctx, cancel := requestContext(c, h.Timeouts.write())
+	defer cancel()
+
+	id, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid product id"})
+	}
+
+	result, err := h.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return dbError(c, err)
+	}
+	if result.DeletedCount == 0 {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "product not found"})
+	}
+
+	// images live in GridFS, so they don't go away with the product document
+	if err := h.deleteProductImages(ctx, id); err != nil {
+		return dbError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
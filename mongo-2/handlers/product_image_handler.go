@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"echo-mongo-api/models"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const maxImageSize = 10 << 20
+
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// UploadProductImage stores the "image" form file in GridFS together with a
+// generated thumbnail. Any image previously attached to the product is
+// replaced once the new files are stored.
+func (h *ProductHandler) UploadProductImage(c echo.Context) error {
+	ctx, cancel := requestContext(c, h.Timeouts.upload())
+	defer cancel()
+
+	productID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid product id"})
+	}
+
+	if err := h.Collection.FindOne(ctx, bson.M{"_id": productID}).Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "product not found"})
+		}
+		return dbError(c, err)
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "missing image form file"})
+	}
+	if fileHeader.Size > maxImageSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": "image is larger than 10MB"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if len(data) > maxImageSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": "image is larger than 10MB"})
+	}
+
+	// trust the bytes, not the client supplied content type
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		return c.JSON(http.StatusUnsupportedMediaType, echo.Map{"error": "image must be jpeg, png or gif"})
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": "could not decode image"})
+	}
+
+	thumbnail, thumbnailType, thumbnailBounds, err := makeThumbnail(img, format)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+	}
+
+	original := models.ImageMetadata{
+		ProductID:   productID,
+		Kind:        models.ImageKindOriginal,
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+	originalID, err := h.Bucket.UploadFromStream(ctx, fileHeader.Filename, bytes.NewReader(data),
+		options.GridFSUpload().SetMetadata(original))
+	if err != nil {
+		return dbError(c, err)
+	}
+
+	thumb := models.ImageMetadata{
+		ProductID:   productID,
+		Kind:        models.ImageKindThumbnail,
+		ContentType: thumbnailType,
+		Width:       thumbnailBounds.Dx(),
+		Height:      thumbnailBounds.Dy(),
+	}
+	thumbnailID, err := h.Bucket.UploadFromStream(ctx, "thumb_"+fileHeader.Filename, bytes.NewReader(thumbnail),
+		options.GridFSUpload().SetMetadata(thumb))
+	if err != nil {
+		h.Bucket.Delete(context.WithoutCancel(ctx), originalID)
+		return dbError(c, err)
+	}
+
+	keep := []bson.ObjectID{originalID, thumbnailID}
+	if err := h.deleteProductImages(ctx, productID, keep...); err != nil {
+		return dbError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"image_id":     originalID,
+		"thumbnail_id": thumbnailID,
+	})
+}
+
+// GetProductImage serves the original image of a product.
+func (h *ProductHandler) GetProductImage(c echo.Context) error {
+	return h.serveProductImage(c, models.ImageKindOriginal)
+}
+
+// GetProductThumbnail serves the generated thumbnail of a product.
+func (h *ProductHandler) GetProductThumbnail(c echo.Context) error {
+	return h.serveProductImage(c, models.ImageKindThumbnail)
+}
+
+// serveProductImage streams the image with http.ServeContent, which takes
+// care of Range, If-Range, If-None-Match and If-Modified-Since requests.
+func (h *ProductHandler) serveProductImage(c echo.Context, kind string) error {
+	ctx, cancel := requestContext(c, h.Timeouts.upload())
+	defer cancel()
+
+	productID, err := bson.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid product id"})
+	}
+
+	var file models.ImageFile
+	filter := bson.M{"metadata.productId": productID, "metadata.kind": kind}
+	err = h.Bucket.GetFilesCollection().
+		FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "uploadDate", Value: -1}})).
+		Decode(&file)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "image not found"})
+		}
+		return dbError(c, err)
+	}
+
+	content := &gridFSFile{ctx: ctx, bucket: h.Bucket, id: file.ID, size: file.Length}
+	defer content.Close()
+
+	// a new upload always gets a new file id, so the id doubles as a strong etag
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, file.Metadata.ContentType)
+	res.Header().Set("ETag", fmt.Sprintf(`"%s"`, file.ID.Hex()))
+	res.Header().Set("Cache-Control", "public, max-age=86400")
+
+	http.ServeContent(res, c.Request(), file.Filename, file.UploadDate, content)
+	return nil
+}
+
+// deleteProductImages removes every GridFS file that belongs to the product,
+// except the ids listed in keep.
+func (h *ProductHandler) deleteProductImages(ctx context.Context, productID bson.ObjectID, keep ...bson.ObjectID) error {
+	filter := bson.M{"metadata.productId": productID}
+	if len(keep) > 0 {
+		filter["_id"] = bson.M{"$nin": keep}
+	}
+
+	cursor, err := h.Bucket.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var files []models.ImageFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := h.Bucket.Delete(ctx, file.ID); err != nil && !errors.Is(err, mongo.ErrFileNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
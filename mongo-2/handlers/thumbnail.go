@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+const thumbnailMaxSide = 256
+
+// makeThumbnail scales img so its longest side is at most thumbnailMaxSide
+// and encodes it in the same format as the original (gifs become pngs, since
+// only the first frame is kept).
+func makeThumbnail(img image.Image, format string) ([]byte, string, image.Rectangle, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > thumbnailMaxSide || height > thumbnailMaxSide {
+		if width >= height {
+			height = height * thumbnailMaxSide / width
+			width = thumbnailMaxSide
+		} else {
+			width = width * thumbnailMaxSide / height
+			height = thumbnailMaxSide
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", image.Rectangle{}, err
+		}
+		return buf.Bytes(), "image/jpeg", dst.Bounds(), nil
+	default:
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", image.Rectangle{}, err
+		}
+		return buf.Bytes(), "image/png", dst.Bounds(), nil
+	}
+}
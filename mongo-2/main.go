@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 func main() {
@@ -22,13 +23,33 @@ func main() {
 
 	db := client.Database("api_test")
 	collection := db.Collection("products")
+	bucket := db.GridFSBucket(options.GridFSBucket().SetName("product_images"))
+
+	timeouts := handlers.Timeouts{
+		Read:   cfg.ReadTimeout,
+		Write:  cfg.WriteTimeout,
+		Upload: cfg.UploadTimeout,
+	}
 
 	e := echo.New()
 
-	productHandler := handlers.ProductHandler{Collection: collection}
+	productHandler := handlers.ProductHandler{Collection: collection, Bucket: bucket, Timeouts: timeouts}
+	orderHandler := handlers.OrderHandler{
+		Client:   client,
+		Products: collection,
+		Orders:   db.Collection("orders"),
+		Timeouts: timeouts,
+	}
 
 	e.GET("/", productHandler.GetProducts)
 	e.POST("/", productHandler.CreateProduct)
+	e.DELETE("/:id", productHandler.DeleteProduct)
+	e.POST("/:id/image", productHandler.UploadProductImage)
+	e.GET("/:id/image", productHandler.GetProductImage)
+	e.GET("/:id/image/thumbnail", productHandler.GetProductThumbnail)
+
+	e.POST("/orders/checkout", orderHandler.Checkout)
+	e.GET("/orders/:id", orderHandler.GetOrder)
 
 	appPort := fmt.Sprintf(":%s", cfg.AppPort)
 	e.Logger.Fatal(e.Start(appPort))
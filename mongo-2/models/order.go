@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type OrderItem struct {
+	ProductID bson.ObjectID `bson:"productId" json:"product_id"`
+	Name      string        `bson:"name" json:"name"`
+	Quantity  int           `bson:"quantity" json:"quantity"`
+	Price     float64       `bson:"price" json:"price"`
+}
+
+type Order struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Items     []OrderItem   `bson:"items" json:"items"`
+	Total     float64       `bson:"total" json:"total"`
+	CreatedAt time.Time     `bson:"createdAt" json:"created_at"`
+}
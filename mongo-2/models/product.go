@@ -1,14 +1,39 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
 
 type Product struct {
-	// ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	// Name        string             `bson:"name" json:"name" validate:"required"`
-	// Description string             `bson:"description" json:"description"`
-	// Price       float64            `bson:"price" json:"price" validate:"required,gt=0"`
-	ID          primitive.ObjectID `json:"id,omitempty"`
-	Name        string             `json:"name" validate:"required"`
-	Description string             `json:"description"`
-	Price       float64            `json:"price" validate:"required,gt=0"`
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        string        `bson:"name" json:"name" validate:"required"`
+	Description string        `bson:"description" json:"description"`
+	Price       float64       `bson:"price" json:"price" validate:"required,gt=0"`
+	Stock       int           `bson:"stock" json:"stock" validate:"gte=0"`
+}
+
+const (
+	ImageKindOriginal  = "original"
+	ImageKindThumbnail = "thumbnail"
+)
+
+// ImageMetadata is stored in the metadata field of every GridFS file that
+// belongs to a product, so images can be found and removed by product id.
+type ImageMetadata struct {
+	ProductID   bson.ObjectID `bson:"productId" json:"product_id"`
+	Kind        string        `bson:"kind" json:"kind"`
+	ContentType string        `bson:"contentType" json:"content_type"`
+	Width       int           `bson:"width" json:"width"`
+	Height      int           `bson:"height" json:"height"`
+}
+
+// ImageFile mirrors a document in the GridFS files collection.
+type ImageFile struct {
+	ID         bson.ObjectID `bson:"_id" json:"id"`
+	Length     int64         `bson:"length" json:"length"`
+	UploadDate time.Time     `bson:"uploadDate" json:"upload_date"`
+	Filename   string        `bson:"filename" json:"filename"`
+	Metadata   ImageMetadata `bson:"metadata" json:"metadata"`
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"mongo-seed/seed"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+const usage = `usage: mongo-seed [flags] <command> [set]
+
+commands:
+  list         list the fixture sets in -dir
+  load SET     upsert the set's documents, safe to run repeatedly
+  reset SET    drop the set's collections, then load it
+
+flags:
+`
+
+func main() {
+	log.SetFlags(0)
+
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("failed to load .env file")
+	}
+
+	fs := flag.NewFlagSet("mongo-seed", flag.ExitOnError)
+	mongoURI := fs.String("mongo-uri", os.Getenv("MONGO_URI"), "MongoDB connection string (MONGO_URI)")
+	dir := fs.String("dir", "fixtures", "directory with the fixture files")
+	database := fs.String("db", "", "database name, overrides the set's database")
+	now := fs.String("now", "", "reference time for generated dates, RFC 3339 (default current time)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "list":
+		names, err := seed.ListSets(*dir)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	case "load", "reset":
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%s needs a fixture set name", fs.Arg(0))
+	}
+
+	set, err := seed.ReadSet(*dir, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	var opts seed.Options
+	if *now != "" {
+		if opts.Now, err = time.Parse(time.RFC3339, *now); err != nil {
+			return fmt.Errorf("invalid -now: %w", err)
+		}
+	}
+
+	dbName := set.Database
+	if *database != "" {
+		dbName = *database
+	}
+	if dbName == "" {
+		return errors.New("no database, set one in the fixture file or pass -db")
+	}
+	if *mongoURI == "" {
+		return errors.New("no MongoDB URI, set MONGO_URI or pass -mongo-uri")
+	}
+
+	client, err := connectMongoDB(*mongoURI)
+	if err != nil {
+		return fmt.Errorf("connecting to MongoDB: %w", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	db := client.Database(dbName)
+
+	var result seed.Result
+	if fs.Arg(0) == "reset" {
+		result, err = seed.Reset(ctx, db, set, opts)
+	} else {
+		result, err = seed.Load(ctx, db, set, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, c := range result.Collections {
+		fmt.Printf("%s.%s: %d inserted, %d updated\n", dbName, c.Name, c.Inserted, c.Updated)
+	}
+	return nil
+}
+
+func connectMongoDB(uri string) (*mongo.Client, error) {
+	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
+	client, err := mongo.Connect(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Ping(ctx, readpref.Primary())
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
@@ -0,0 +1,119 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Set is a named group of collections, loaded from one fixture file. The name
+// is the file name without its extension, so fixtures/dev.yaml is set "dev".
+type Set struct {
+	Name        string       `yaml:"-" json:"-"`
+	Database    string       `yaml:"database" json:"database"`
+	Collections []Collection `yaml:"collections" json:"collections"`
+}
+
+// Collection lists literal documents and, optionally, a template to generate
+// more. Collections are loaded in file order, so a collection can only
+// reference documents of collections above it.
+type Collection struct {
+	Name      string           `yaml:"name" json:"name"`
+	Documents []map[string]any `yaml:"documents" json:"documents"`
+	Generate  *Generate        `yaml:"generate" json:"generate"`
+}
+
+type Generate struct {
+	Count    int            `yaml:"count" json:"count"`
+	Template map[string]any `yaml:"template" json:"template"`
+}
+
+var extensions = []string{".yaml", ".yml", ".json"}
+
+// ReadSet finds the fixture file for name in dir and parses it.
+func ReadSet(dir, name string) (*Set, error) {
+	for _, ext := range extensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return ReadFile(path)
+		}
+	}
+	return nil, fmt.Errorf("no fixture set %q in %s", name, dir)
+}
+
+// ReadFile parses a YAML or JSON fixture file, chosen by its extension.
+func ReadFile(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		// UseNumber keeps integers as integers instead of float64
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		dec.DisallowUnknownFields()
+		err = dec.Decode(set)
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := set.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return set, nil
+}
+
+// ListSets returns the names of all fixture sets in dir.
+func ListSets(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains(extensions, ext) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ext))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *Set) validate() error {
+	seen := map[string]bool{}
+	for _, c := range s.Collections {
+		if c.Name == "" {
+			return fmt.Errorf("collection without a name")
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("collection %q is listed twice", c.Name)
+		}
+		seen[c.Name] = true
+
+		if c.Generate != nil && c.Generate.Count < 0 {
+			return fmt.Errorf("collection %q: generate count can't be negative", c.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package seed loads fixture sets into MongoDB. Loading is idempotent: every
+// document gets an _id derived from the set, collection and _ref (or position),
+// and is upserted, so running the same set twice leaves one copy of each
+// document.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// refKey is the fixture-only field naming a document for ref expressions.
+// It is stripped before the document is written.
+const refKey = "_ref"
+
+type Options struct {
+	// Now is the reference time for "now" and fake.date. Pin it to get
+	// byte-for-byte identical data between runs. Defaults to time.Now().
+	Now time.Time
+}
+
+// Result counts documents per collection.
+type Result struct {
+	Collections []CollectionResult
+}
+
+type CollectionResult struct {
+	Name     string
+	Inserted int64
+	Updated  int64
+}
+
+// Load upserts every document of the set into db.
+func Load(ctx context.Context, db *mongo.Database, set *Set, opts Options) (Result, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	opts.Now = opts.Now.UTC().Truncate(time.Millisecond)
+
+	refs := newRefTable()
+	var result Result
+
+	for _, c := range set.Collections {
+		docs, err := buildDocuments(set.Name, c, refs, opts.Now)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", c.Name, err)
+		}
+
+		res := CollectionResult{Name: c.Name}
+		if len(docs) > 0 {
+			models := make([]mongo.WriteModel, len(docs))
+			for i, doc := range docs {
+				models[i] = mongo.NewReplaceOneModel().
+					SetFilter(bson.M{"_id": doc["_id"]}).
+					SetReplacement(doc).
+					SetUpsert(true)
+			}
+
+			bw, err := db.Collection(c.Name).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", c.Name, err)
+			}
+			res.Inserted = bw.UpsertedCount
+			res.Updated = bw.MatchedCount
+		}
+
+		result.Collections = append(result.Collections, res)
+	}
+
+	return result, nil
+}
+
+// Reset drops every collection named in the set and loads it again, so the
+// collections contain exactly the fixture data. Collections the set doesn't
+// mention are left alone.
+func Reset(ctx context.Context, db *mongo.Database, set *Set, opts Options) (Result, error) {
+	for _, c := range set.Collections {
+		if err := db.Collection(c.Name).Drop(ctx); err != nil {
+			return Result{}, fmt.Errorf("dropping %s: %w", c.Name, err)
+		}
+	}
+	return Load(ctx, db, set, opts)
+}
+
+// buildDocuments renders the literal documents first and then the generated
+// ones, registering each _id so later documents can reference it.
+func buildDocuments(setName string, c Collection, refs *refTable, now time.Time) ([]bson.M, error) {
+	var docs []bson.M
+
+	add := func(index int, src map[string]any, fallbackKey string) error {
+		r := newRenderer(setName, c.Name, index, refs, now)
+
+		rendered, err := r.render(src)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", index, err)
+		}
+		doc := rendered.(bson.M)
+
+		// unnamed documents still get a stable id from their position, they
+		// just can't be referenced by key
+		key, name := fallbackKey, ""
+		if v, ok := doc[refKey]; ok {
+			s, ok := v.(string)
+			if !ok || s == "" {
+				return fmt.Errorf("document %d: _ref must be a non-empty string", index)
+			}
+			key, name = s, s
+			delete(doc, refKey)
+		}
+
+		if _, ok := doc["_id"]; !ok {
+			doc["_id"] = documentID(setName, c.Name, key)
+		}
+
+		if err := refs.add(c.Name, name, doc["_id"]); err != nil {
+			return err
+		}
+
+		docs = append(docs, doc)
+		return nil
+	}
+
+	for i, src := range c.Documents {
+		if err := add(i, src, "doc-"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Generate != nil {
+		offset := len(c.Documents)
+		for i := 0; i < c.Generate.Count; i++ {
+			index := offset + i
+			if err := add(index, c.Generate.Template, "gen-"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,298 @@
+package seed
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var exprPattern = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// renderer fills in the {{ ... }} expressions of one document. Its random
+// source is seeded from the document's position in the set, so loading the
+// same set twice produces the same values and the same ids.
+type renderer struct {
+	set   string
+	coll  string
+	index int
+	rnd   *rand.Rand
+	refs  *refTable
+	now   time.Time
+}
+
+func newRenderer(set, coll string, index int, refs *refTable, now time.Time) *renderer {
+	h := sha1.Sum([]byte(fmt.Sprintf("%s/%s/%d", set, coll, index)))
+	seed1 := binary.BigEndian.Uint64(h[0:8])
+	seed2 := binary.BigEndian.Uint64(h[8:16])
+
+	return &renderer{
+		set:   set,
+		coll:  coll,
+		index: index,
+		rnd:   rand.New(rand.NewPCG(seed1, seed2)),
+		refs:  refs,
+		now:   now,
+	}
+}
+
+func (r *renderer) render(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		// keys in a fixed order, so each field draws the same random values
+		// from r.rnd on every load
+		out := make(bson.M, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			rendered, err := r.render(v[key])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make(bson.A, len(v))
+		for i, value := range v {
+			rendered, err := r.render(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i), nil
+		}
+		return v.Float64()
+	case string:
+		return r.renderString(v)
+	default:
+		return v, nil
+	}
+}
+
+// renderString keeps the type of the value when the string is exactly one
+// expression ("{{ fake.int 1 5 }}" becomes a number), and interpolates text
+// otherwise.
+func (r *renderer) renderString(s string) (any, error) {
+	matches := exprPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return r.eval(s[matches[0][2]:matches[0][3]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		value, err := r.eval(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		if id, ok := value.(bson.ObjectID); ok {
+			b.WriteString(id.Hex())
+		} else {
+			fmt.Fprint(&b, value)
+		}
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+
+	return b.String(), nil
+}
+
+func (r *renderer) eval(expr string) (any, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "i":
+		return r.index, nil
+	case "now":
+		return r.now, nil
+	case "objectId":
+		var id bson.ObjectID
+		for i := range id {
+			id[i] = byte(r.rnd.UintN(256))
+		}
+		return id, nil
+	case "ref":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("ref takes one argument, like ref users.alice")
+		}
+		return r.refs.resolve(args[0], r.rnd)
+	}
+
+	if fake, ok := fakes[name]; ok {
+		return fake(r.rnd, r.now, args)
+	}
+
+	return nil, fmt.Errorf("unknown expression %q", name)
+}
+
+// refTable remembers the _id of every document loaded so far, by collection
+// and by the document's _ref key.
+type refTable struct {
+	byKey map[string]map[string]any
+	all   map[string][]any
+}
+
+func newRefTable() *refTable {
+	return &refTable{byKey: map[string]map[string]any{}, all: map[string][]any{}}
+}
+
+func (t *refTable) add(coll, key string, id any) error {
+	if t.byKey[coll] == nil {
+		t.byKey[coll] = map[string]any{}
+	}
+	if key != "" {
+		if _, dup := t.byKey[coll][key]; dup {
+			return fmt.Errorf("duplicate _ref %q in %s", key, coll)
+		}
+		t.byKey[coll][key] = id
+	}
+	t.all[coll] = append(t.all[coll], id)
+	return nil
+}
+
+// resolve looks up "collection.key". A key of "*" picks one of the
+// collection's documents at random.
+func (t *refTable) resolve(target string, rnd *rand.Rand) (any, error) {
+	coll, key, ok := strings.Cut(target, ".")
+	if !ok {
+		return nil, fmt.Errorf("ref %q must look like collection.key", target)
+	}
+
+	if key == "*" {
+		ids := t.all[coll]
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("ref %q: no documents loaded in %s yet", target, coll)
+		}
+		return ids[rnd.IntN(len(ids))], nil
+	}
+
+	id, ok := t.byKey[coll][key]
+	if !ok {
+		return nil, fmt.Errorf("ref %q: no document with that _ref loaded yet", target)
+	}
+	return id, nil
+}
+
+// documentID derives a stable ObjectID from where the document sits in the
+// fixtures, which is what makes reloading a set an upsert instead of a
+// duplicate insert.
+func documentID(set, coll, key string) bson.ObjectID {
+	h := sha1.Sum([]byte(set + "/" + coll + "/" + key))
+	var id bson.ObjectID
+	copy(id[:], h[:len(id)])
+	return id
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carla", "Dmitri", "Erin", "Farah", "Gus", "Hana", "Ivan", "Jade", "Kofi", "Lena", "Mateo", "Nia", "Omar", "Priya"}
+	lastNames  = []string{"Anders", "Baker", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Haddad", "Ito", "Jensen", "Khan", "Lopez", "Moreau", "Novak", "Okafor", "Park"}
+	words      = []string{"amber", "basic", "cloud", "delta", "ember", "fresh", "grain", "harbor", "iron", "jolly", "kettle", "lunar", "maple", "noble", "orbit", "prime", "quartz", "river", "solar", "timber"}
+	domains    = []string{"example.com", "example.net", "example.org"}
+)
+
+type fakeFunc func(rnd *rand.Rand, now time.Time, args []string) (any, error)
+
+var fakes = map[string]fakeFunc{
+	"fake.firstName": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		return pick(rnd, firstNames), nil
+	},
+	"fake.lastName": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		return pick(rnd, lastNames), nil
+	},
+	"fake.name": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		return pick(rnd, firstNames) + " " + pick(rnd, lastNames), nil
+	},
+	"fake.email": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		user := strings.ToLower(pick(rnd, firstNames) + "." + pick(rnd, lastNames))
+		return fmt.Sprintf("%s%d@%s", user, rnd.IntN(1000), pick(rnd, domains)), nil
+	},
+	"fake.word": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		return pick(rnd, words), nil
+	},
+	"fake.sentence": func(rnd *rand.Rand, _ time.Time, args []string) (any, error) {
+		n := 6
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return nil, fmt.Errorf("fake.sentence: invalid word count %q", args[0])
+			}
+		}
+		list := make([]string, n)
+		for i := range list {
+			list[i] = pick(rnd, words)
+		}
+		sentence := strings.Join(list, " ")
+		return strings.ToUpper(sentence[:1]) + sentence[1:] + ".", nil
+	},
+	"fake.int": func(rnd *rand.Rand, _ time.Time, args []string) (any, error) {
+		lo, hi, err := intRange(args)
+		if err != nil {
+			return nil, fmt.Errorf("fake.int: %w", err)
+		}
+		// int, like yaml integers, so a field keeps one bson type whether
+		// it was written out or generated
+		return int(lo + rnd.Int64N(hi-lo+1)), nil
+	},
+	"fake.float": func(rnd *rand.Rand, _ time.Time, args []string) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("fake.float takes min and max")
+		}
+		lo, err1 := strconv.ParseFloat(args[0], 64)
+		hi, err2 := strconv.ParseFloat(args[1], 64)
+		if err1 != nil || err2 != nil || hi < lo {
+			return nil, fmt.Errorf("fake.float: invalid range %s %s", args[0], args[1])
+		}
+		// two decimals is what every price field wants anyway
+		v := lo + rnd.Float64()*(hi-lo)
+		return float64(int64(v*100)) / 100, nil
+	},
+	"fake.bool": func(rnd *rand.Rand, _ time.Time, _ []string) (any, error) {
+		return rnd.IntN(2) == 1, nil
+	},
+	"fake.date": func(rnd *rand.Rand, now time.Time, _ []string) (any, error) {
+		// somewhere in the year before the load
+		return now.Add(-time.Duration(rnd.Int64N(int64(365 * 24 * time.Hour)))).Truncate(time.Second), nil
+	},
+	"fake.pick": func(rnd *rand.Rand, _ time.Time, args []string) (any, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("fake.pick needs at least one choice")
+		}
+		return pick(rnd, args), nil
+	},
+}
+
+func pick(rnd *rand.Rand, list []string) string {
+	return list[rnd.IntN(len(list))]
+}
+
+func intRange(args []string) (int64, int64, error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("takes min and max")
+	}
+	lo, err1 := strconv.ParseInt(args[0], 10, 64)
+	hi, err2 := strconv.ParseInt(args[1], 10, 64)
+	if err1 != nil || err2 != nil || hi < lo {
+		return 0, 0, fmt.Errorf("invalid range %s %s", args[0], args[1])
+	}
+	return lo, hi, nil
+}
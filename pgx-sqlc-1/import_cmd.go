@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pgx-sqlc-1/internal/importer"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func runImport(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	table := fs.String("table", "gamers", "table to load: gamers or todos")
+	format := fs.String("format", "", "csv or ndjson (default: from the file extension)")
+	rejectsPath := fs.String("rejects", "", "file for rejected rows (default: FILE.rejects)")
+	batchSize := fs.Int("batch", importer.DefaultBatchSize, "rows per COPY batch")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: pgx-sqlc-1 import [flags] FILE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one input file")
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = "csv"
+		case ".ndjson", ".jsonl":
+			*format = "ndjson"
+		default:
+			return errors.New("can't tell the format from the file name, pass -format")
+		}
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if *rejectsPath == "" {
+		*rejectsPath = path + ".rejects"
+	}
+	rejects, err := os.Create(*rejectsPath)
+	if err != nil {
+		return err
+	}
+	defer rejects.Close()
+
+	stats, err := importer.Import(ctx, pool, in, importer.Options{
+		Table:     *table,
+		Format:    *format,
+		BatchSize: *batchSize,
+		Rejects:   rejects,
+		Progress: func(s importer.Stats) {
+			fmt.Fprintf(os.Stderr, "\r%d read, %d imported, %d rejected", s.Read, s.Imported, s.Rejected)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("import failed after %d rows, %d were imported before that: %w", stats.Read, stats.Imported, err)
+	}
+
+	fmt.Printf("imported %d of %d rows into %s in %s (%.0f rows/s)\n",
+		stats.Imported, stats.Read, *table, stats.Elapsed.Round(1e6), stats.RowsPerSecond())
+
+	if stats.Rejected > 0 {
+		fmt.Printf("%d rows rejected, see %s\n", stats.Rejected, *rejectsPath)
+		return nil
+	}
+
+	// don't leave an empty rejects file around
+	rejects.Close()
+	return os.Remove(*rejectsPath)
+}
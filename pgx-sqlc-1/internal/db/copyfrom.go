@@ -0,0 +1,77 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: copyfrom.go
+
+package db
+
+import (
+	"context"
+)
+
+// iteratorForCopyGamers implements pgx.CopyFromSource.
+type iteratorForCopyGamers struct {
+	rows                 []CopyGamersParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCopyGamers) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCopyGamers) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].FirstName,
+		r.rows[0].LastName,
+	}, nil
+}
+
+func (r iteratorForCopyGamers) Err() error {
+	return nil
+}
+
+func (q *Queries) CopyGamers(ctx context.Context, arg []CopyGamersParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"gamers"}, []string{"first_name", "last_name"}, &iteratorForCopyGamers{rows: arg})
+}
+
+// iteratorForCopyTodos implements pgx.CopyFromSource.
+type iteratorForCopyTodos struct {
+	rows                 []CopyTodosParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCopyTodos) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCopyTodos) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].UserID,
+		r.rows[0].Task,
+		r.rows[0].Done,
+	}, nil
+}
+
+func (r iteratorForCopyTodos) Err() error {
+	return nil
+}
+
+func (q *Queries) CopyTodos(ctx context.Context, arg []CopyTodosParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"todos"}, []string{"user_id", "task", "done"}, &iteratorForCopyTodos{rows: arg})
+}
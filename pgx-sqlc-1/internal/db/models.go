@@ -4,15 +4,87 @@
 
 package db
 
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type FriendEdge struct {
+	GamerID  int32 `db:"gamer_id" json:"gamer_id"`
+	FriendID int32 `db:"friend_id" json:"friend_id"`
+}
+
+type Friendship struct {
+	RequesterID int32              `db:"requester_id" json:"requester_id"`
+	AddresseeID int32              `db:"addressee_id" json:"addressee_id"`
+	Status      string             `db:"status" json:"status"`
+	CreatedAt   pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
 type Gamer struct {
 	ID        int32  `db:"id" json:"id"`
 	FirstName string `db:"first_name" json:"first_name"`
 	LastName  string `db:"last_name" json:"last_name"`
 }
 
+type Leaderboard struct {
+	Period      string             `db:"period" json:"period"`
+	GamerID     int32              `db:"gamer_id" json:"gamer_id"`
+	Points      int64              `db:"points" json:"points"`
+	Matches     int64              `db:"matches" json:"matches"`
+	Rank        int64              `db:"rank" json:"rank"`
+	Position    int64              `db:"position" json:"position"`
+	RefreshedAt pgtype.Timestamptz `db:"refreshed_at" json:"refreshed_at"`
+}
+
+type RankSnapshot struct {
+	ID      int64              `db:"id" json:"id"`
+	GamerID int32              `db:"gamer_id" json:"gamer_id"`
+	Period  string             `db:"period" json:"period"`
+	Rank    int64              `db:"rank" json:"rank"`
+	Points  int64              `db:"points" json:"points"`
+	TakenAt pgtype.Timestamptz `db:"taken_at" json:"taken_at"`
+}
+
+type Score struct {
+	ID       int32              `db:"id" json:"id"`
+	GamerID  int32              `db:"gamer_id" json:"gamer_id"`
+	Points   int32              `db:"points" json:"points"`
+	PlayedAt pgtype.Timestamptz `db:"played_at" json:"played_at"`
+}
+
 type Todo struct {
-	ID     int32  `db:"id" json:"id"`
-	UserID int32  `db:"user_id" json:"user_id"`
-	Task   string `db:"task" json:"task"`
-	Done   bool   `db:"done" json:"done"`
+	ID             int32              `db:"id" json:"id"`
+	UserID         int32              `db:"user_id" json:"user_id"`
+	Task           string             `db:"task" json:"task"`
+	Done           bool               `db:"done" json:"done"`
+	DueAt          pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone       string             `db:"time_zone" json:"time_zone"`
+	Priority       int16              `db:"priority" json:"priority"`
+	Recurrence     pgtype.Text        `db:"recurrence" json:"recurrence"`
+	SeriesID       pgtype.Int4        `db:"series_id" json:"series_id"`
+	Occurrence     int32              `db:"occurrence" json:"occurrence"`
+	Position       string             `db:"position" json:"position"`
+	Version        int64              `db:"version" json:"version"`
+	ClientID       pgtype.UUID        `db:"client_id" json:"client_id"`
+	FieldUpdatedAt []byte             `db:"field_updated_at" json:"field_updated_at"`
+	SeriesStart    pgtype.Timestamptz `db:"series_start" json:"series_start"`
+}
+
+type TodoReminder struct {
+	ID           int64              `db:"id" json:"id"`
+	TodoID       int32              `db:"todo_id" json:"todo_id"`
+	RemindAt     pgtype.Timestamptz `db:"remind_at" json:"remind_at"`
+	SentAt       pgtype.Timestamptz `db:"sent_at" json:"sent_at"`
+	Attempts     int32              `db:"attempts" json:"attempts"`
+	LastError    pgtype.Text        `db:"last_error" json:"last_error"`
+	ClaimedUntil pgtype.Timestamptz `db:"claimed_until" json:"claimed_until"`
+}
+
+type TodoTombstone struct {
+	TodoID    int32              `db:"todo_id" json:"todo_id"`
+	UserID    int32              `db:"user_id" json:"user_id"`
+	ClientID  pgtype.UUID        `db:"client_id" json:"client_id"`
+	Version   int64              `db:"version" json:"version"`
+	DeletedAt pgtype.Timestamptz `db:"deleted_at" json:"deleted_at"`
 }
@@ -7,8 +7,256 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const acceptFriendship = `-- name: AcceptFriendship :one
+UPDATE friendships
+SET status = 'accepted',
+    updated_at = now()
+WHERE requester_id = $1
+    AND addressee_id = $2
+    AND status = 'pending'
+RETURNING requester_id, addressee_id, status, created_at, updated_at
+`
+
+type AcceptFriendshipParams struct {
+	RequesterID int32 `db:"requester_id" json:"requester_id"`
+	AddresseeID int32 `db:"addressee_id" json:"addressee_id"`
+}
+
+func (q *Queries) AcceptFriendship(ctx context.Context, arg AcceptFriendshipParams) (Friendship, error) {
+	row := q.db.QueryRow(ctx, acceptFriendship, arg.RequesterID, arg.AddresseeID)
+	var i Friendship
+	err := row.Scan(
+		&i.RequesterID,
+		&i.AddresseeID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const blockGamer = `-- name: BlockGamer :one
+INSERT INTO friendships (requester_id, addressee_id, status)
+VALUES ($1, $2, 'blocked') ON CONFLICT (
+        least(requester_id, addressee_id),
+        greatest(requester_id, addressee_id)
+    ) DO
+UPDATE
+SET requester_id = excluded.requester_id,
+    addressee_id = excluded.addressee_id,
+    status = 'blocked',
+    updated_at = now()
+WHERE friendships.status <> 'blocked'
+    OR friendships.requester_id = excluded.requester_id
+RETURNING requester_id, addressee_id, status, created_at, updated_at
+`
+
+type BlockGamerParams struct {
+	BlockerID int32 `db:"blocker_id" json:"blocker_id"`
+	BlockedID int32 `db:"blocked_id" json:"blocked_id"`
+}
+
+// turns whatever is between the two gamers into a block by blocker_id, unless
+// the other gamer blocked first.
+func (q *Queries) BlockGamer(ctx context.Context, arg BlockGamerParams) (Friendship, error) {
+	row := q.db.QueryRow(ctx, blockGamer, arg.BlockerID, arg.BlockedID)
+	var i Friendship
+	err := row.Scan(
+		&i.RequesterID,
+		&i.AddresseeID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const claimDueReminders = `-- name: ClaimDueReminders :many
+UPDATE todo_reminders r
+SET claimed_until = now() + make_interval(secs => $1::float8)
+FROM todos t
+WHERE t.id = r.todo_id
+    AND r.id IN (
+        SELECT d.id
+        FROM todo_reminders d
+            JOIN todos dt ON dt.id = d.todo_id
+        WHERE d.sent_at IS NULL
+            AND (
+                d.claimed_until IS NULL
+                OR d.claimed_until <= now()
+            )
+            AND d.remind_at + make_interval(mins => d.attempts * d.attempts) <= now()
+            AND d.attempts < $2::int
+            AND NOT dt.done
+        ORDER BY d.remind_at
+        LIMIT $3 FOR
+        UPDATE OF d SKIP LOCKED
+    )
+RETURNING r.id,
+    r.todo_id,
+    r.remind_at,
+    r.attempts,
+    t.user_id,
+    t.task,
+    t.due_at,
+    t.time_zone,
+    t.priority
+`
+
+type ClaimDueRemindersParams struct {
+	LeaseSeconds float64 `db:"lease_seconds" json:"lease_seconds"`
+	MaxAttempts  int32   `db:"max_attempts" json:"max_attempts"`
+	BatchSize    int32   `db:"batch_size" json:"batch_size"`
+}
+
+type ClaimDueRemindersRow struct {
+	ID       int64              `db:"id" json:"id"`
+	TodoID   int32              `db:"todo_id" json:"todo_id"`
+	RemindAt pgtype.Timestamptz `db:"remind_at" json:"remind_at"`
+	Attempts int32              `db:"attempts" json:"attempts"`
+	UserID   int32              `db:"user_id" json:"user_id"`
+	Task     string             `db:"task" json:"task"`
+	DueAt    pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone string             `db:"time_zone" json:"time_zone"`
+	Priority int16              `db:"priority" json:"priority"`
+}
+
+// leases a batch of due reminders until now() + lease_seconds. SKIP LOCKED
+// lets several workers claim at once without waiting on each other, the
+// lease keeps the batch away from them while it is being sent. Failed
+// reminders are retried attempts^2 minutes after remind_at.
+func (q *Queries) ClaimDueReminders(ctx context.Context, arg ClaimDueRemindersParams) ([]ClaimDueRemindersRow, error) {
+	rows, err := q.db.Query(ctx, claimDueReminders, arg.LeaseSeconds, arg.MaxAttempts, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimDueRemindersRow
+	for rows.Next() {
+		var i ClaimDueRemindersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TodoID,
+			&i.RemindAt,
+			&i.Attempts,
+			&i.UserID,
+			&i.Task,
+			&i.DueAt,
+			&i.TimeZone,
+			&i.Priority,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type CopyGamersParams struct {
+	FirstName string `db:"first_name" json:"first_name"`
+	LastName  string `db:"last_name" json:"last_name"`
+}
+
+const copyRemindersToOccurrence = `-- name: CopyRemindersToOccurrence :execrows
+INSERT INTO todo_reminders (todo_id, remind_at)
+SELECT $1::int,
+    remind_at + (
+        $2::timestamptz - $3::timestamptz
+    )
+FROM todo_reminders
+WHERE todo_id = $4::int
+`
+
+type CopyRemindersToOccurrenceParams struct {
+	ToTodoID   int32              `db:"to_todo_id" json:"to_todo_id"`
+	ToDueAt    pgtype.Timestamptz `db:"to_due_at" json:"to_due_at"`
+	FromDueAt  pgtype.Timestamptz `db:"from_due_at" json:"from_due_at"`
+	FromTodoID int32              `db:"from_todo_id" json:"from_todo_id"`
+}
+
+// gives the next occurrence the same reminders, moved by as much as the due
+// date moved
+func (q *Queries) CopyRemindersToOccurrence(ctx context.Context, arg CopyRemindersToOccurrenceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, copyRemindersToOccurrence,
+		arg.ToTodoID,
+		arg.ToDueAt,
+		arg.FromDueAt,
+		arg.FromTodoID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+type CopyTodosParams struct {
+	UserID int32  `db:"user_id" json:"user_id"`
+	Task   string `db:"task" json:"task"`
+	Done   bool   `db:"done" json:"done"`
+}
+
+const countTodosByGamer = `-- name: CountTodosByGamer :one
+SELECT count(*) FILTER (WHERE NOT done) AS open,
+    count(*) FILTER (WHERE done) AS closed
+FROM todos
+WHERE user_id = $1
+`
+
+type CountTodosByGamerRow struct {
+	Open   int64 `db:"open" json:"open"`
+	Closed int64 `db:"closed" json:"closed"`
+}
+
+func (q *Queries) CountTodosByGamer(ctx context.Context, userID int32) (CountTodosByGamerRow, error) {
+	row := q.db.QueryRow(ctx, countTodosByGamer, userID)
+	var i CountTodosByGamerRow
+	err := row.Scan(&i.Open, &i.Closed)
+	return i, err
+}
+
+const countTodosPerGamer = `-- name: CountTodosPerGamer :many
+SELECT g.id AS gamer_id,
+    count(t.id) FILTER (WHERE NOT t.done) AS open,
+    count(t.id) FILTER (WHERE t.done) AS closed
+FROM gamers g
+    LEFT JOIN todos t ON t.user_id = g.id
+GROUP BY g.id
+ORDER BY g.id
+`
+
+type CountTodosPerGamerRow struct {
+	GamerID int32 `db:"gamer_id" json:"gamer_id"`
+	Open    int64 `db:"open" json:"open"`
+	Closed  int64 `db:"closed" json:"closed"`
+}
+
+func (q *Queries) CountTodosPerGamer(ctx context.Context) ([]CountTodosPerGamerRow, error) {
+	rows, err := q.db.Query(ctx, countTodosPerGamer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountTodosPerGamerRow
+	for rows.Next() {
+		var i CountTodosPerGamerRow
+		if err := rows.Scan(&i.GamerID, &i.Open, &i.Closed); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createGamer = `-- name: CreateGamer :one
 INSERT INTO gamers (first_name, last_name)
 VALUES ($1, $2)
@@ -27,30 +275,358 @@ func (q *Queries) CreateGamer(ctx context.Context, arg CreateGamerParams) (Gamer
 	return i, err
 }
 
+const createNextOccurrence = `-- name: CreateNextOccurrence :one
+INSERT INTO todos (
+        user_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence,
+        series_id,
+        series_start,
+        occurrence
+    )
+VALUES (
+        $1,
+        $2,
+        false,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9
+    ) ON CONFLICT (series_id, occurrence) DO NOTHING
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+type CreateNextOccurrenceParams struct {
+	UserID      int32              `db:"user_id" json:"user_id"`
+	Task        string             `db:"task" json:"task"`
+	DueAt       pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone    string             `db:"time_zone" json:"time_zone"`
+	Priority    int16              `db:"priority" json:"priority"`
+	Recurrence  pgtype.Text        `db:"recurrence" json:"recurrence"`
+	SeriesID    pgtype.Int4        `db:"series_id" json:"series_id"`
+	SeriesStart pgtype.Timestamptz `db:"series_start" json:"series_start"`
+	Occurrence  int32              `db:"occurrence" json:"occurrence"`
+}
+
+// returns no rows when the occurrence already exists
+func (q *Queries) CreateNextOccurrence(ctx context.Context, arg CreateNextOccurrenceParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, createNextOccurrence,
+		arg.UserID,
+		arg.Task,
+		arg.DueAt,
+		arg.TimeZone,
+		arg.Priority,
+		arg.Recurrence,
+		arg.SeriesID,
+		arg.SeriesStart,
+		arg.Occurrence,
+	)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const createScore = `-- name: CreateScore :one
+INSERT INTO scores (gamer_id, points, played_at)
+VALUES (
+        $1,
+        $2,
+        coalesce($3::timestamptz, now())
+    )
+RETURNING id, gamer_id, points, played_at
+`
+
+type CreateScoreParams struct {
+	GamerID  int32              `db:"gamer_id" json:"gamer_id"`
+	Points   int32              `db:"points" json:"points"`
+	PlayedAt pgtype.Timestamptz `db:"played_at" json:"played_at"`
+}
+
+func (q *Queries) CreateScore(ctx context.Context, arg CreateScoreParams) (Score, error) {
+	row := q.db.QueryRow(ctx, createScore, arg.GamerID, arg.Points, arg.PlayedAt)
+	var i Score
+	err := row.Scan(
+		&i.ID,
+		&i.GamerID,
+		&i.Points,
+		&i.PlayedAt,
+	)
+	return i, err
+}
+
+const createSyncedTodo = `-- name: CreateSyncedTodo :one
+INSERT INTO todos (
+        user_id,
+        client_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence,
+        field_updated_at
+    )
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+type CreateSyncedTodoParams struct {
+	UserID         int32              `db:"user_id" json:"user_id"`
+	ClientID       pgtype.UUID        `db:"client_id" json:"client_id"`
+	Task           string             `db:"task" json:"task"`
+	Done           bool               `db:"done" json:"done"`
+	DueAt          pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone       string             `db:"time_zone" json:"time_zone"`
+	Priority       int16              `db:"priority" json:"priority"`
+	Recurrence     pgtype.Text        `db:"recurrence" json:"recurrence"`
+	FieldUpdatedAt []byte             `db:"field_updated_at" json:"field_updated_at"`
+}
+
+func (q *Queries) CreateSyncedTodo(ctx context.Context, arg CreateSyncedTodoParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, createSyncedTodo,
+		arg.UserID,
+		arg.ClientID,
+		arg.Task,
+		arg.Done,
+		arg.DueAt,
+		arg.TimeZone,
+		arg.Priority,
+		arg.Recurrence,
+		arg.FieldUpdatedAt,
+	)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
 const createTodo = `-- name: CreateTodo :one
-INSERT INTO todos (user_id, task, done)
-VALUES ($1, $2, $3)
-RETURNING id, user_id, task, done
+INSERT INTO todos (
+        user_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence
+    )
+VALUES (
+        $1,
+        $2,
+        $3,
+        $4,
+        coalesce($5::text, 'UTC'),
+        coalesce($6::smallint, 0),
+        $7
+    )
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
 `
 
 type CreateTodoParams struct {
-	UserID int32  `db:"user_id" json:"user_id"`
-	Task   string `db:"task" json:"task"`
-	Done   bool   `db:"done" json:"done"`
+	UserID     int32              `db:"user_id" json:"user_id"`
+	Task       string             `db:"task" json:"task"`
+	Done       bool               `db:"done" json:"done"`
+	DueAt      pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone   pgtype.Text        `db:"time_zone" json:"time_zone"`
+	Priority   pgtype.Int2        `db:"priority" json:"priority"`
+	Recurrence pgtype.Text        `db:"recurrence" json:"recurrence"`
 }
 
 func (q *Queries) CreateTodo(ctx context.Context, arg CreateTodoParams) (Todo, error) {
-	row := q.db.QueryRow(ctx, createTodo, arg.UserID, arg.Task, arg.Done)
+	row := q.db.QueryRow(ctx, createTodo,
+		arg.UserID,
+		arg.Task,
+		arg.Done,
+		arg.DueAt,
+		arg.TimeZone,
+		arg.Priority,
+		arg.Recurrence,
+	)
 	var i Todo
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
 		&i.Task,
 		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
 	)
 	return i, err
 }
 
+const createTodoReminder = `-- name: CreateTodoReminder :one
+INSERT INTO todo_reminders (todo_id, remind_at)
+VALUES ($1, $2)
+RETURNING id, todo_id, remind_at, sent_at, attempts, last_error, claimed_until
+`
+
+type CreateTodoReminderParams struct {
+	TodoID   int32              `db:"todo_id" json:"todo_id"`
+	RemindAt pgtype.Timestamptz `db:"remind_at" json:"remind_at"`
+}
+
+func (q *Queries) CreateTodoReminder(ctx context.Context, arg CreateTodoReminderParams) (TodoReminder, error) {
+	row := q.db.QueryRow(ctx, createTodoReminder, arg.TodoID, arg.RemindAt)
+	var i TodoReminder
+	err := row.Scan(
+		&i.ID,
+		&i.TodoID,
+		&i.RemindAt,
+		&i.SentAt,
+		&i.Attempts,
+		&i.LastError,
+		&i.ClaimedUntil,
+	)
+	return i, err
+}
+
+const deleteGamer = `-- name: DeleteGamer :execrows
+DELETE FROM gamers
+WHERE id = $1
+`
+
+func (q *Queries) DeleteGamer(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteGamer, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTodo = `-- name: DeleteTodo :execrows
+DELETE FROM todos
+WHERE id = $1
+`
+
+func (q *Queries) DeleteTodo(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTodo, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTodoReminder = `-- name: DeleteTodoReminder :execrows
+DELETE FROM todo_reminders
+WHERE id = $1
+    AND todo_id = $2
+`
+
+type DeleteTodoReminderParams struct {
+	ID     int64 `db:"id" json:"id"`
+	TodoID int32 `db:"todo_id" json:"todo_id"`
+}
+
+func (q *Queries) DeleteTodoReminder(ctx context.Context, arg DeleteTodoReminderParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTodoReminder, arg.ID, arg.TodoID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const existingGamerIDs = `-- name: ExistingGamerIDs :many
+SELECT id
+FROM gamers
+WHERE id = ANY($1::int [])
+`
+
+func (q *Queries) ExistingGamerIDs(ctx context.Context, ids []int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, existingGamerIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const gamersWithLongTodoPositions = `-- name: GamersWithLongTodoPositions :many
+SELECT DISTINCT user_id
+FROM todos
+WHERE length(position) > 12
+LIMIT $1
+`
+
+// the predicate is the one of todos_long_position_user_id_idx, keep them
+// and service.RebalanceLength in sync
+func (q *Queries) GamersWithLongTodoPositions(ctx context.Context, maxGamers int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, gamersWithLongTodoPositions, maxGamers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var user_id int32
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getGamer = `-- name: GetGamer :one
 SELECT id, first_name, last_name
 FROM gamers
@@ -89,12 +665,1268 @@ func (q *Queries) GetGamers(ctx context.Context) ([]Gamer, error) {
 	return items, nil
 }
 
-const deleteGamer = `-- name: deleteGamer :exec
-DELETE FROM gamers
-Where id = $1
+const getGamersByIDs = `-- name: GetGamersByIDs :many
+SELECT id, first_name, last_name
+FROM gamers
+WHERE id = ANY($1::int [])
 `
 
-func (q *Queries) deleteGamer(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteGamer, id)
-	return err
+func (q *Queries) GetGamersByIDs(ctx context.Context, ids []int32) ([]Gamer, error) {
+	rows, err := q.db.Query(ctx, getGamersByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Gamer
+	for rows.Next() {
+		var i Gamer
+		if err := rows.Scan(&i.ID, &i.FirstName, &i.LastName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTodo = `-- name: GetTodo :one
+SELECT id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+FROM todos
+WHERE id = $1
+`
+
+func (q *Queries) GetTodo(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, getTodo, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const getTodoByClientID = `-- name: GetTodoByClientID :one
+SELECT id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+FROM todos
+WHERE client_id = $1 FOR
+UPDATE
+`
+
+func (q *Queries) GetTodoByClientID(ctx context.Context, clientID pgtype.UUID) (Todo, error) {
+	row := q.db.QueryRow(ctx, getTodoByClientID, clientID)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const getTodoForUpdate = `-- name: GetTodoForUpdate :one
+SELECT id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+FROM todos
+WHERE id = $1 FOR
+UPDATE
+`
+
+func (q *Queries) GetTodoForUpdate(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, getTodoForUpdate, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const getTombstone = `-- name: GetTombstone :one
+SELECT todo_id, user_id, client_id, version, deleted_at
+FROM todo_tombstones
+WHERE user_id = $1
+    AND (
+        todo_id = $2
+        OR client_id = $3
+    )
+LIMIT 1
+`
+
+type GetTombstoneParams struct {
+	UserID   int32       `db:"user_id" json:"user_id"`
+	TodoID   pgtype.Int4 `db:"todo_id" json:"todo_id"`
+	ClientID pgtype.UUID `db:"client_id" json:"client_id"`
+}
+
+// only the gamer's own tombstones: a change naming another gamer's
+// deleted todo is rejected, like one naming their live todo
+func (q *Queries) GetTombstone(ctx context.Context, arg GetTombstoneParams) (TodoTombstone, error) {
+	row := q.db.QueryRow(ctx, getTombstone, arg.UserID, arg.TodoID, arg.ClientID)
+	var i TodoTombstone
+	err := row.Scan(
+		&i.TodoID,
+		&i.UserID,
+		&i.ClientID,
+		&i.Version,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const listFriends = `-- name: ListFriends :many
+SELECT g.id, g.first_name, g.last_name
+FROM friend_edges e
+    JOIN gamers g ON g.id = e.friend_id
+WHERE e.gamer_id = $1
+ORDER BY g.last_name,
+    g.first_name,
+    g.id
+`
+
+func (q *Queries) ListFriends(ctx context.Context, gamerID int32) ([]Gamer, error) {
+	rows, err := q.db.Query(ctx, listFriends, gamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Gamer
+	for rows.Next() {
+		var i Gamer
+		if err := rows.Scan(&i.ID, &i.FirstName, &i.LastName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGamersByFirstName = `-- name: ListGamersByFirstName :many
+SELECT id, first_name, last_name
+FROM gamers
+WHERE (
+        $1::text IS NULL
+        OR (first_name, last_name, id) > (
+            $1::text,
+            $2::text,
+            $3::int
+        )
+    )
+    AND (
+        $4::text IS NULL
+        OR lower(first_name) LIKE $4
+        OR lower(last_name) LIKE $4
+    )
+ORDER BY first_name,
+    last_name,
+    id
+LIMIT $5
+`
+
+type ListGamersByFirstNameParams struct {
+	AfterFirstName pgtype.Text `db:"after_first_name" json:"after_first_name"`
+	AfterLastName  string      `db:"after_last_name" json:"after_last_name"`
+	AfterID        int32       `db:"after_id" json:"after_id"`
+	NamePrefix     pgtype.Text `db:"name_prefix" json:"name_prefix"`
+	PageSize       int32       `db:"page_size" json:"page_size"`
+}
+
+func (q *Queries) ListGamersByFirstName(ctx context.Context, arg ListGamersByFirstNameParams) ([]Gamer, error) {
+	rows, err := q.db.Query(ctx, listGamersByFirstName,
+		arg.AfterFirstName,
+		arg.AfterLastName,
+		arg.AfterID,
+		arg.NamePrefix,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Gamer
+	for rows.Next() {
+		var i Gamer
+		if err := rows.Scan(&i.ID, &i.FirstName, &i.LastName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGamersByID = `-- name: ListGamersByID :many
+SELECT id, first_name, last_name
+FROM gamers
+WHERE id > $1::int
+    AND (
+        $2::text IS NULL
+        OR lower(first_name) LIKE $2
+        OR lower(last_name) LIKE $2
+    )
+ORDER BY id
+LIMIT $3
+`
+
+type ListGamersByIDParams struct {
+	AfterID    int32       `db:"after_id" json:"after_id"`
+	NamePrefix pgtype.Text `db:"name_prefix" json:"name_prefix"`
+	PageSize   int32       `db:"page_size" json:"page_size"`
+}
+
+func (q *Queries) ListGamersByID(ctx context.Context, arg ListGamersByIDParams) ([]Gamer, error) {
+	rows, err := q.db.Query(ctx, listGamersByID, arg.AfterID, arg.NamePrefix, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Gamer
+	for rows.Next() {
+		var i Gamer
+		if err := rows.Scan(&i.ID, &i.FirstName, &i.LastName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGamersByLastName = `-- name: ListGamersByLastName :many
+SELECT id, first_name, last_name
+FROM gamers
+WHERE (
+        $1::text IS NULL
+        OR (last_name, first_name, id) > (
+            $1::text,
+            $2::text,
+            $3::int
+        )
+    )
+    AND (
+        $4::text IS NULL
+        OR lower(first_name) LIKE $4
+        OR lower(last_name) LIKE $4
+    )
+ORDER BY last_name,
+    first_name,
+    id
+LIMIT $5
+`
+
+type ListGamersByLastNameParams struct {
+	AfterLastName  pgtype.Text `db:"after_last_name" json:"after_last_name"`
+	AfterFirstName string      `db:"after_first_name" json:"after_first_name"`
+	AfterID        int32       `db:"after_id" json:"after_id"`
+	NamePrefix     pgtype.Text `db:"name_prefix" json:"name_prefix"`
+	PageSize       int32       `db:"page_size" json:"page_size"`
+}
+
+func (q *Queries) ListGamersByLastName(ctx context.Context, arg ListGamersByLastNameParams) ([]Gamer, error) {
+	rows, err := q.db.Query(ctx, listGamersByLastName,
+		arg.AfterLastName,
+		arg.AfterFirstName,
+		arg.AfterID,
+		arg.NamePrefix,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Gamer
+	for rows.Next() {
+		var i Gamer
+		if err := rows.Scan(&i.ID, &i.FirstName, &i.LastName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listIncomingFriendRequests = `-- name: ListIncomingFriendRequests :many
+SELECT g.id, g.first_name, g.last_name,
+    f.created_at AS requested_at
+FROM friendships f
+    JOIN gamers g ON g.id = f.requester_id
+WHERE f.addressee_id = $1
+    AND f.status = 'pending'
+ORDER BY f.created_at
+`
+
+type ListIncomingFriendRequestsRow struct {
+	ID          int32              `db:"id" json:"id"`
+	FirstName   string             `db:"first_name" json:"first_name"`
+	LastName    string             `db:"last_name" json:"last_name"`
+	RequestedAt pgtype.Timestamptz `db:"requested_at" json:"requested_at"`
+}
+
+func (q *Queries) ListIncomingFriendRequests(ctx context.Context, addresseeID int32) ([]ListIncomingFriendRequestsRow, error) {
+	rows, err := q.db.Query(ctx, listIncomingFriendRequests, addresseeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListIncomingFriendRequestsRow
+	for rows.Next() {
+		var i ListIncomingFriendRequestsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.RequestedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLeaderboard = `-- name: ListLeaderboard :many
+SELECT l.gamer_id,
+    g.first_name,
+    g.last_name,
+    l.points,
+    l.matches,
+    l.rank,
+    l.position,
+    l.refreshed_at
+FROM leaderboard l
+    JOIN gamers g ON g.id = l.gamer_id
+WHERE l.period = $1
+    AND l.position > $2
+ORDER BY l.position
+LIMIT $3
+`
+
+type ListLeaderboardParams struct {
+	Period        string `db:"period" json:"period"`
+	AfterPosition int64  `db:"after_position" json:"after_position"`
+	PageSize      int32  `db:"page_size" json:"page_size"`
+}
+
+type ListLeaderboardRow struct {
+	GamerID     int32              `db:"gamer_id" json:"gamer_id"`
+	FirstName   string             `db:"first_name" json:"first_name"`
+	LastName    string             `db:"last_name" json:"last_name"`
+	Points      int64              `db:"points" json:"points"`
+	Matches     int64              `db:"matches" json:"matches"`
+	Rank        int64              `db:"rank" json:"rank"`
+	Position    int64              `db:"position" json:"position"`
+	RefreshedAt pgtype.Timestamptz `db:"refreshed_at" json:"refreshed_at"`
+}
+
+func (q *Queries) ListLeaderboard(ctx context.Context, arg ListLeaderboardParams) ([]ListLeaderboardRow, error) {
+	rows, err := q.db.Query(ctx, listLeaderboard, arg.Period, arg.AfterPosition, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLeaderboardRow
+	for rows.Next() {
+		var i ListLeaderboardRow
+		if err := rows.Scan(
+			&i.GamerID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Points,
+			&i.Matches,
+			&i.Rank,
+			&i.Position,
+			&i.RefreshedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLeaderboardAroundGamer = `-- name: ListLeaderboardAroundGamer :many
+SELECT l.gamer_id,
+    g.first_name,
+    g.last_name,
+    l.points,
+    l.matches,
+    l.rank,
+    l.position,
+    l.refreshed_at
+FROM leaderboard me
+    JOIN leaderboard l ON l.period = me.period
+    AND l.position BETWEEN me.position - $1 AND me.position + $1
+    JOIN gamers g ON g.id = l.gamer_id
+WHERE me.period = $2
+    AND me.gamer_id = $3
+ORDER BY l.position
+`
+
+type ListLeaderboardAroundGamerParams struct {
+	Spread  int64  `db:"spread" json:"spread"`
+	Period  string `db:"period" json:"period"`
+	GamerID int32  `db:"gamer_id" json:"gamer_id"`
+}
+
+type ListLeaderboardAroundGamerRow struct {
+	GamerID     int32              `db:"gamer_id" json:"gamer_id"`
+	FirstName   string             `db:"first_name" json:"first_name"`
+	LastName    string             `db:"last_name" json:"last_name"`
+	Points      int64              `db:"points" json:"points"`
+	Matches     int64              `db:"matches" json:"matches"`
+	Rank        int64              `db:"rank" json:"rank"`
+	Position    int64              `db:"position" json:"position"`
+	RefreshedAt pgtype.Timestamptz `db:"refreshed_at" json:"refreshed_at"`
+}
+
+func (q *Queries) ListLeaderboardAroundGamer(ctx context.Context, arg ListLeaderboardAroundGamerParams) ([]ListLeaderboardAroundGamerRow, error) {
+	rows, err := q.db.Query(ctx, listLeaderboardAroundGamer, arg.Spread, arg.Period, arg.GamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLeaderboardAroundGamerRow
+	for rows.Next() {
+		var i ListLeaderboardAroundGamerRow
+		if err := rows.Scan(
+			&i.GamerID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Points,
+			&i.Matches,
+			&i.Rank,
+			&i.Position,
+			&i.RefreshedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRankHistory = `-- name: ListRankHistory :many
+SELECT rank,
+    points,
+    taken_at
+FROM rank_snapshots
+WHERE gamer_id = $1
+    AND period = $2
+    AND taken_at >= $3
+ORDER BY taken_at
+`
+
+type ListRankHistoryParams struct {
+	GamerID int32              `db:"gamer_id" json:"gamer_id"`
+	Period  string             `db:"period" json:"period"`
+	Since   pgtype.Timestamptz `db:"since" json:"since"`
+}
+
+type ListRankHistoryRow struct {
+	Rank    int64              `db:"rank" json:"rank"`
+	Points  int64              `db:"points" json:"points"`
+	TakenAt pgtype.Timestamptz `db:"taken_at" json:"taken_at"`
+}
+
+func (q *Queries) ListRankHistory(ctx context.Context, arg ListRankHistoryParams) ([]ListRankHistoryRow, error) {
+	rows, err := q.db.Query(ctx, listRankHistory, arg.GamerID, arg.Period, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRankHistoryRow
+	for rows.Next() {
+		var i ListRankHistoryRow
+		if err := rows.Scan(&i.Rank, &i.Points, &i.TakenAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoIDsByPosition = `-- name: ListTodoIDsByPosition :many
+SELECT id
+FROM todos
+WHERE user_id = $1
+ORDER BY position
+`
+
+func (q *Queries) ListTodoIDsByPosition(ctx context.Context, userID int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listTodoIDsByPosition, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoReminders = `-- name: ListTodoReminders :many
+SELECT id, todo_id, remind_at, sent_at, attempts, last_error, claimed_until
+FROM todo_reminders
+WHERE todo_id = $1
+ORDER BY remind_at
+`
+
+func (q *Queries) ListTodoReminders(ctx context.Context, todoID int32) ([]TodoReminder, error) {
+	rows, err := q.db.Query(ctx, listTodoReminders, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TodoReminder
+	for rows.Next() {
+		var i TodoReminder
+		if err := rows.Scan(
+			&i.ID,
+			&i.TodoID,
+			&i.RemindAt,
+			&i.SentAt,
+			&i.Attempts,
+			&i.LastError,
+			&i.ClaimedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodosByGamer = `-- name: ListTodosByGamer :many
+SELECT id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+FROM todos
+WHERE user_id = $1
+ORDER BY position
+`
+
+func (q *Queries) ListTodosByGamer(ctx context.Context, userID int32) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, listTodosByGamer, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Todo
+	for rows.Next() {
+		var i Todo
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Task,
+			&i.Done,
+			&i.DueAt,
+			&i.TimeZone,
+			&i.Priority,
+			&i.Recurrence,
+			&i.SeriesID,
+			&i.Occurrence,
+			&i.Position,
+			&i.Version,
+			&i.ClientID,
+			&i.FieldUpdatedAt,
+			&i.SeriesStart,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodosChangedSince = `-- name: ListTodosChangedSince :many
+SELECT id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+FROM todos
+WHERE user_id = $1
+    AND version > $2
+ORDER BY version
+LIMIT $3
+`
+
+type ListTodosChangedSinceParams struct {
+	UserID  int32 `db:"user_id" json:"user_id"`
+	Version int64 `db:"version" json:"version"`
+	MaxRows int32 `db:"max_rows" json:"max_rows"`
+}
+
+func (q *Queries) ListTodosChangedSince(ctx context.Context, arg ListTodosChangedSinceParams) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, listTodosChangedSince, arg.UserID, arg.Version, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Todo
+	for rows.Next() {
+		var i Todo
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Task,
+			&i.Done,
+			&i.DueAt,
+			&i.TimeZone,
+			&i.Priority,
+			&i.Recurrence,
+			&i.SeriesID,
+			&i.Occurrence,
+			&i.Position,
+			&i.Version,
+			&i.ClientID,
+			&i.FieldUpdatedAt,
+			&i.SeriesStart,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTombstonesSince = `-- name: ListTombstonesSince :many
+SELECT todo_id, user_id, client_id, version, deleted_at
+FROM todo_tombstones
+WHERE user_id = $1
+    AND version > $2
+ORDER BY version
+LIMIT $3
+`
+
+type ListTombstonesSinceParams struct {
+	UserID  int32 `db:"user_id" json:"user_id"`
+	Version int64 `db:"version" json:"version"`
+	MaxRows int32 `db:"max_rows" json:"max_rows"`
+}
+
+func (q *Queries) ListTombstonesSince(ctx context.Context, arg ListTombstonesSinceParams) ([]TodoTombstone, error) {
+	rows, err := q.db.Query(ctx, listTombstonesSince, arg.UserID, arg.Version, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TodoTombstone
+	for rows.Next() {
+		var i TodoTombstone
+		if err := rows.Scan(
+			&i.TodoID,
+			&i.UserID,
+			&i.ClientID,
+			&i.Version,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockTodoOrder = `-- name: LockTodoOrder :exec
+SELECT pg_advisory_xact_lock('todos'::regclass::oid::int, $1::int)
+`
+
+// serializes changes to a gamer's todo order until the transaction ends,
+// the insert trigger takes the same lock
+func (q *Queries) LockTodoOrder(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, lockTodoOrder, userID)
+	return err
+}
+
+const markReminderFailed = `-- name: MarkReminderFailed :exec
+UPDATE todo_reminders
+SET attempts = attempts + 1,
+    last_error = $1::text,
+    claimed_until = NULL
+WHERE id = $2
+`
+
+type MarkReminderFailedParams struct {
+	LastError string `db:"last_error" json:"last_error"`
+	ID        int64  `db:"id" json:"id"`
+}
+
+func (q *Queries) MarkReminderFailed(ctx context.Context, arg MarkReminderFailedParams) error {
+	_, err := q.db.Exec(ctx, markReminderFailed, arg.LastError, arg.ID)
+	return err
+}
+
+const markReminderSent = `-- name: MarkReminderSent :exec
+UPDATE todo_reminders
+SET sent_at = now(),
+    attempts = attempts + 1,
+    last_error = NULL,
+    claimed_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) MarkReminderSent(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, markReminderSent, id)
+	return err
+}
+
+const nextTodoPosition = `-- name: NextTodoPosition :one
+SELECT position
+FROM todos
+WHERE user_id = $1
+    AND position > $2
+    AND id <> $3
+ORDER BY position
+LIMIT 1
+`
+
+type NextTodoPositionParams struct {
+	UserID    int32  `db:"user_id" json:"user_id"`
+	Position  string `db:"position" json:"position"`
+	ExcludeID int32  `db:"exclude_id" json:"exclude_id"`
+}
+
+func (q *Queries) NextTodoPosition(ctx context.Context, arg NextTodoPositionParams) (string, error) {
+	row := q.db.QueryRow(ctx, nextTodoPosition, arg.UserID, arg.Position, arg.ExcludeID)
+	var position string
+	err := row.Scan(&position)
+	return position, err
+}
+
+const prevTodoPosition = `-- name: PrevTodoPosition :one
+SELECT position
+FROM todos
+WHERE user_id = $1
+    AND position < $2
+    AND id <> $3
+ORDER BY position DESC
+LIMIT 1
+`
+
+type PrevTodoPositionParams struct {
+	UserID    int32  `db:"user_id" json:"user_id"`
+	Position  string `db:"position" json:"position"`
+	ExcludeID int32  `db:"exclude_id" json:"exclude_id"`
+}
+
+func (q *Queries) PrevTodoPosition(ctx context.Context, arg PrevTodoPositionParams) (string, error) {
+	row := q.db.QueryRow(ctx, prevTodoPosition, arg.UserID, arg.Position, arg.ExcludeID)
+	var position string
+	err := row.Scan(&position)
+	return position, err
+}
+
+const refreshLeaderboard = `-- name: RefreshLeaderboard :exec
+REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard
+`
+
+func (q *Queries) RefreshLeaderboard(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, refreshLeaderboard)
+	return err
+}
+
+const removeFriendship = `-- name: RemoveFriendship :execrows
+DELETE FROM friendships
+WHERE least(requester_id, addressee_id) = least($1::int, $2::int)
+    AND greatest(requester_id, addressee_id) = greatest($1::int, $2::int)
+    AND (
+        status <> 'blocked'
+        OR requester_id = $1::int
+    )
+`
+
+type RemoveFriendshipParams struct {
+	GamerID int32 `db:"gamer_id" json:"gamer_id"`
+	OtherID int32 `db:"other_id" json:"other_id"`
+}
+
+// declines or cancels a request, unfriends, or lifts a block. Only the
+// blocker can lift a block.
+func (q *Queries) RemoveFriendship(ctx context.Context, arg RemoveFriendshipParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeFriendship, arg.GamerID, arg.OtherID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const requestFriendship = `-- name: RequestFriendship :one
+INSERT INTO friendships (requester_id, addressee_id)
+VALUES ($1, $2) ON CONFLICT (
+        least(requester_id, addressee_id),
+        greatest(requester_id, addressee_id)
+    ) DO
+UPDATE
+SET status = 'accepted',
+    updated_at = now()
+WHERE friendships.status = 'pending'
+    AND friendships.requester_id = excluded.addressee_id
+RETURNING requester_id, addressee_id, status, created_at, updated_at
+`
+
+type RequestFriendshipParams struct {
+	RequesterID int32 `db:"requester_id" json:"requester_id"`
+	AddresseeID int32 `db:"addressee_id" json:"addressee_id"`
+}
+
+// a request towards someone who already asked us accepts theirs. any other
+// existing row (pending, accepted or blocked) is left alone and nothing is
+// returned.
+func (q *Queries) RequestFriendship(ctx context.Context, arg RequestFriendshipParams) (Friendship, error) {
+	row := q.db.QueryRow(ctx, requestFriendship, arg.RequesterID, arg.AddresseeID)
+	var i Friendship
+	err := row.Scan(
+		&i.RequesterID,
+		&i.AddresseeID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setTodoPositions = `-- name: SetTodoPositions :exec
+UPDATE todos
+SET position = ($1::text [])[array_position($2::int [], id)]
+WHERE user_id = $3
+    AND id = ANY($2::int [])
+`
+
+type SetTodoPositionsParams struct {
+	Positions []string `db:"positions" json:"positions"`
+	Ids       []int32  `db:"ids" json:"ids"`
+	UserID    int32    `db:"user_id" json:"user_id"`
+}
+
+// positions[i] is the new key of ids[i]
+func (q *Queries) SetTodoPositions(ctx context.Context, arg SetTodoPositionsParams) error {
+	_, err := q.db.Exec(ctx, setTodoPositions, arg.Positions, arg.Ids, arg.UserID)
+	return err
+}
+
+const shortestFriendPath = `-- name: ShortestFriendPath :one
+WITH RECURSIVE walk (gamer_id, path) AS (
+    SELECT $2::int,
+        ARRAY [$2::int]
+    UNION ALL
+    SELECT DISTINCT ON (e.friend_id) e.friend_id,
+        w.path || e.friend_id
+    FROM walk w
+        JOIN friend_edges e ON e.gamer_id = w.gamer_id
+    WHERE cardinality(w.path) <= $3::int
+        AND w.gamer_id <> $1::int
+        AND NOT e.friend_id = ANY(w.path)
+)
+SELECT path::int []
+FROM walk
+WHERE gamer_id = $1::int
+ORDER BY cardinality(path)
+LIMIT 1
+`
+
+type ShortestFriendPathParams struct {
+	ToID    int32 `db:"to_id" json:"to_id"`
+	FromID  int32 `db:"from_id" json:"from_id"`
+	MaxHops int32 `db:"max_hops" json:"max_hops"`
+}
+
+// breadth first over friend_edges from from_id. DISTINCT ON in the
+// recursive term keeps one path per gamer and hop, so the walk holds at
+// most one row per gamer within reach at each hop instead of every path.
+// Paths never revisit a gamer. Returns the gamer ids from from_id to to_id,
+// or no rows when they aren't connected within max_hops.
+func (q *Queries) ShortestFriendPath(ctx context.Context, arg ShortestFriendPathParams) ([]int32, error) {
+	row := q.db.QueryRow(ctx, shortestFriendPath, arg.ToID, arg.FromID, arg.MaxHops)
+	var path []int32
+	err := row.Scan(&path)
+	return path, err
+}
+
+const snapshotRanks = `-- name: SnapshotRanks :execrows
+INSERT INTO rank_snapshots (gamer_id, period, rank, points, taken_at)
+SELECT l.gamer_id,
+    l.period,
+    l.rank,
+    l.points,
+    l.refreshed_at
+FROM leaderboard l
+    LEFT JOIN LATERAL (
+        SELECT s.rank,
+            s.points
+        FROM rank_snapshots s
+        WHERE s.gamer_id = l.gamer_id
+            AND s.period = l.period
+        ORDER BY s.taken_at DESC
+        LIMIT 1
+    ) last ON true
+WHERE last.rank IS DISTINCT FROM l.rank
+    OR last.points IS DISTINCT FROM l.points
+`
+
+func (q *Queries) SnapshotRanks(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, snapshotRanks)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const suggestFriends = `-- name: SuggestFriends :many
+WITH RECURSIVE reach (gamer_id, depth) AS (
+    SELECT $1::int,
+        0
+    UNION ALL
+    SELECT DISTINCT e.friend_id,
+        r.depth + 1
+    FROM reach r
+        JOIN friend_edges e ON e.gamer_id = r.gamer_id
+    WHERE r.depth < $3::int
+),
+candidates AS (
+    SELECT gamer_id,
+        min(depth) AS distance
+    FROM reach
+    WHERE gamer_id <> $1::int
+    GROUP BY gamer_id
+)
+SELECT g.id,
+    g.first_name,
+    g.last_name,
+    c.distance::int AS distance,
+    (
+        SELECT count(*)
+        FROM friend_edges mine
+            JOIN friend_edges theirs ON theirs.gamer_id = mine.friend_id
+        WHERE mine.gamer_id = $1::int
+            AND theirs.friend_id = c.gamer_id
+    ) AS mutual_friends
+FROM candidates c
+    JOIN gamers g ON g.id = c.gamer_id
+WHERE c.distance >= 2
+    AND NOT EXISTS (
+        SELECT 1
+        FROM friendships f
+        WHERE least(f.requester_id, f.addressee_id) = least(c.gamer_id, $1::int)
+            AND greatest(f.requester_id, f.addressee_id) = greatest(c.gamer_id, $1::int)
+    )
+ORDER BY distance,
+    mutual_friends DESC,
+    g.id
+LIMIT $2
+`
+
+type SuggestFriendsParams struct {
+	GamerID  int32 `db:"gamer_id" json:"gamer_id"`
+	PageSize int32 `db:"page_size" json:"page_size"`
+	MaxDepth int32 `db:"max_depth" json:"max_depth"`
+}
+
+type SuggestFriendsRow struct {
+	ID            int32  `db:"id" json:"id"`
+	FirstName     string `db:"first_name" json:"first_name"`
+	LastName      string `db:"last_name" json:"last_name"`
+	Distance      int32  `db:"distance" json:"distance"`
+	MutualFriends int64  `db:"mutual_friends" json:"mutual_friends"`
+}
+
+// walks the friends graph up to max_depth hops and suggests everyone reached
+// in two or more hops that has no friendship, request or block with the
+// gamer yet. DISTINCT in the recursive term applies to each hop on its own,
+// so a gamer is carried at most once per hop however many paths lead to
+// it; distance is the first hop that reached it. mutual_friends is the
+// number of friends in common.
+func (q *Queries) SuggestFriends(ctx context.Context, arg SuggestFriendsParams) ([]SuggestFriendsRow, error) {
+	rows, err := q.db.Query(ctx, suggestFriends, arg.GamerID, arg.PageSize, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SuggestFriendsRow
+	for rows.Next() {
+		var i SuggestFriendsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Distance,
+			&i.MutualFriends,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const toggleTodo = `-- name: ToggleTodo :one
+UPDATE todos
+SET done = NOT done
+WHERE id = $1
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+func (q *Queries) ToggleTodo(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, toggleTodo, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const updateGamer = `-- name: UpdateGamer :one
+UPDATE gamers
+SET first_name = $2,
+    last_name = $3
+WHERE id = $1
+RETURNING id, first_name, last_name
+`
+
+type UpdateGamerParams struct {
+	ID        int32  `db:"id" json:"id"`
+	FirstName string `db:"first_name" json:"first_name"`
+	LastName  string `db:"last_name" json:"last_name"`
+}
+
+func (q *Queries) UpdateGamer(ctx context.Context, arg UpdateGamerParams) (Gamer, error) {
+	row := q.db.QueryRow(ctx, updateGamer, arg.ID, arg.FirstName, arg.LastName)
+	var i Gamer
+	err := row.Scan(&i.ID, &i.FirstName, &i.LastName)
+	return i, err
+}
+
+const updateSyncedTodo = `-- name: UpdateSyncedTodo :one
+UPDATE todos
+SET task = $2,
+    done = $3,
+    due_at = $4,
+    time_zone = $5,
+    priority = $6,
+    recurrence = $7,
+    field_updated_at = $8
+WHERE id = $1
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+type UpdateSyncedTodoParams struct {
+	ID             int32              `db:"id" json:"id"`
+	Task           string             `db:"task" json:"task"`
+	Done           bool               `db:"done" json:"done"`
+	DueAt          pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone       string             `db:"time_zone" json:"time_zone"`
+	Priority       int16              `db:"priority" json:"priority"`
+	Recurrence     pgtype.Text        `db:"recurrence" json:"recurrence"`
+	FieldUpdatedAt []byte             `db:"field_updated_at" json:"field_updated_at"`
+}
+
+func (q *Queries) UpdateSyncedTodo(ctx context.Context, arg UpdateSyncedTodoParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, updateSyncedTodo,
+		arg.ID,
+		arg.Task,
+		arg.Done,
+		arg.DueAt,
+		arg.TimeZone,
+		arg.Priority,
+		arg.Recurrence,
+		arg.FieldUpdatedAt,
+	)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const updateTodo = `-- name: UpdateTodo :one
+UPDATE todos
+SET task = $1,
+    done = $2,
+    due_at = $3,
+    time_zone = coalesce($4::text, 'UTC'),
+    priority = coalesce($5::smallint, 0),
+    recurrence = $6
+WHERE id = $7
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+type UpdateTodoParams struct {
+	Task       string             `db:"task" json:"task"`
+	Done       bool               `db:"done" json:"done"`
+	DueAt      pgtype.Timestamptz `db:"due_at" json:"due_at"`
+	TimeZone   pgtype.Text        `db:"time_zone" json:"time_zone"`
+	Priority   pgtype.Int2        `db:"priority" json:"priority"`
+	Recurrence pgtype.Text        `db:"recurrence" json:"recurrence"`
+	ID         int32              `db:"id" json:"id"`
+}
+
+func (q *Queries) UpdateTodo(ctx context.Context, arg UpdateTodoParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, updateTodo,
+		arg.Task,
+		arg.Done,
+		arg.DueAt,
+		arg.TimeZone,
+		arg.Priority,
+		arg.Recurrence,
+		arg.ID,
+	)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
+}
+
+const updateTodoPosition = `-- name: UpdateTodoPosition :one
+UPDATE todos
+SET position = $2
+WHERE id = $1
+RETURNING id, user_id, task, done, due_at, time_zone, priority, recurrence, series_id, occurrence, position, version, client_id, field_updated_at, series_start
+`
+
+type UpdateTodoPositionParams struct {
+	ID       int32  `db:"id" json:"id"`
+	Position string `db:"position" json:"position"`
+}
+
+func (q *Queries) UpdateTodoPosition(ctx context.Context, arg UpdateTodoPositionParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, updateTodoPosition, arg.ID, arg.Position)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Task,
+		&i.Done,
+		&i.DueAt,
+		&i.TimeZone,
+		&i.Priority,
+		&i.Recurrence,
+		&i.SeriesID,
+		&i.Occurrence,
+		&i.Position,
+		&i.Version,
+		&i.ClientID,
+		&i.FieldUpdatedAt,
+		&i.SeriesStart,
+	)
+	return i, err
 }
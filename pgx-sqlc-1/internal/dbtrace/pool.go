@@ -0,0 +1,68 @@
+package dbtrace
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports pool.Stat() at scrape time.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+	newConnsCount        *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+name, help, nil, nil)
+	}
+
+	return &PoolCollector{
+		pool:                 pool,
+		acquireCount:         desc("acquire_total", "Successful connection acquires."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Time spent waiting for successful acquires."),
+		acquiredConns:        desc("acquired_conns", "Connections currently in use."),
+		canceledAcquireCount: desc("canceled_acquire_total", "Acquires cancelled by their context."),
+		constructingConns:    desc("constructing_conns", "Connections being opened."),
+		emptyAcquireCount:    desc("empty_acquire_total", "Acquires that had to wait because the pool was empty."),
+		idleConns:            desc("idle_conns", "Idle connections."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		totalConns:           desc("total_conns", "All connections: idle, in use and being opened."),
+		newConnsCount:        desc("new_conns_total", "Connections opened since the pool was created."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+
+	counter(c.acquireCount, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	counter(c.canceledAcquireCount, float64(s.CanceledAcquireCount()))
+	gauge(c.constructingConns, float64(s.ConstructingConns()))
+	counter(c.emptyAcquireCount, float64(s.EmptyAcquireCount()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	counter(c.newConnsCount, float64(s.NewConnsCount()))
+}
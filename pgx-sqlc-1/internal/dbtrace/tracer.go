@@ -0,0 +1,131 @@
+// Package dbtrace records every query run through pgx: a latency histogram
+// per sqlc query name and a log line for queries slower than a threshold.
+package dbtrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sqlc puts "-- name: GetGamer :one" at the top of every query it generates
+var namePattern = regexp.MustCompile(`^\s*-- name: (\w+)`)
+
+// Tracer implements pgx.QueryTracer and pgx.CopyFromTracer.
+type Tracer struct {
+	// SlowThreshold is the duration from which a query is logged, zero
+	// disables the slow query log.
+	SlowThreshold time.Duration
+	Logger        *log.Logger
+
+	duration *prometheus.HistogramVec
+}
+
+var (
+	_ pgx.QueryTracer    = (*Tracer)(nil)
+	_ pgx.CopyFromTracer = (*Tracer)(nil)
+)
+
+func NewTracer(slowThreshold time.Duration) *Tracer {
+	return &Tracer{
+		SlowThreshold: slowThreshold,
+		Logger:        log.Default(),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of database queries by sqlc query name.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"query", "status"}),
+	}
+}
+
+// Collectors returns the metrics to register.
+func (t *Tracer) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{t.duration}
+}
+
+type traceKey struct{}
+
+type trace struct {
+	name  string
+	args  []any
+	start time.Time
+}
+
+func (t *Tracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceKey{}, &trace{
+		name:  QueryName(data.SQL),
+		args:  data.Args,
+		start: time.Now(),
+	})
+}
+
+func (t *Tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *Tracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return context.WithValue(ctx, traceKey{}, &trace{
+		name:  "copy " + data.TableName.Sanitize(),
+		start: time.Now(),
+	})
+}
+
+func (t *Tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *Tracer) end(ctx context.Context, err error) {
+	tr, ok := ctx.Value(traceKey{}).(*trace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(tr.start)
+
+	// no rows is an answer, not a failure
+	status := "ok"
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		status = "error"
+	}
+	t.duration.WithLabelValues(tr.name, status).Observe(elapsed.Seconds())
+
+	if t.SlowThreshold > 0 && elapsed >= t.SlowThreshold {
+		t.Logger.Printf("slow query %s took %s args=%s status=%s",
+			tr.name, elapsed.Round(time.Microsecond), RedactArgs(tr.args), status)
+	}
+}
+
+// QueryName returns the sqlc name of a query, or "other" for SQL that
+// didn't come from sqlc (migrations, LISTEN, ...). Anything else would give
+// the histogram one label per distinct statement.
+func QueryName(sql string) string {
+	if m := namePattern.FindStringSubmatch(sql); m != nil {
+		return m[1]
+	}
+	return "other"
+}
+
+// RedactArgs describes the arguments without their values, which may hold
+// personal data: strings only show their length.
+func RedactArgs(args []any) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			parts[i] = fmt.Sprintf("$%d=null", i+1)
+		case string:
+			parts[i] = fmt.Sprintf("$%d=string(%d)", i+1, len(v))
+		case []byte:
+			parts[i] = fmt.Sprintf("$%d=bytes(%d)", i+1, len(v))
+		default:
+			parts[i] = fmt.Sprintf("$%d=%T", i+1, v)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
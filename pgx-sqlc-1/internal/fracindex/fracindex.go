@@ -0,0 +1,124 @@
+// Package fracindex generates sort keys that always leave room between two
+// neighbours, so moving an item only rewrites that item's key.
+//
+// A key is a base 62 fraction: "V" is 0.5, "0V" is 0.5/62. Keys compare
+// correctly as plain byte strings, which is why the column uses COLLATE "C".
+// Keys never end in '0', otherwise "A" and "A0" would be equal fractions
+// with different strings.
+package fracindex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var base = int64(len(Digits))
+
+// Validate reports whether key is a well formed key.
+func Validate(key string) error {
+	if key == "" {
+		return errors.New("fracindex: empty key")
+	}
+	if key[len(key)-1] == Digits[0] {
+		return fmt.Errorf("fracindex: key %q ends in %q", key, Digits[0])
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(Digits, key[i]) < 0 {
+			return fmt.Errorf("fracindex: invalid character %q in key %q", key[i], key)
+		}
+	}
+	return nil
+}
+
+// Between returns a key strictly between a and b. An empty a means the
+// start of the list and an empty b the end, so Between("", "") is the key
+// of the first item.
+func Between(a, b string) (string, error) {
+	if a != "" {
+		if err := Validate(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := Validate(b); err != nil {
+			return "", err
+		}
+		if a >= b {
+			return "", fmt.Errorf("fracindex: %q is not before %q", a, b)
+		}
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint is the shortest key between a and b, where b == "" stands for 1.
+func midpoint(a, b string) string {
+	if b != "" {
+		// copy the common prefix, a is padded with zeros to b's length
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(Digits, a[0])
+	}
+	digitB := len(Digits)
+	if b != "" {
+		digitB = strings.IndexByte(Digits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(Digits[(digitA+digitB+1)/2])
+	}
+	// the first digits are adjacent: b's first digit alone is already
+	// between when b continues, otherwise go one level deeper after a
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(Digits[digitA]) + midpoint(suffix(a, 1), "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return Digits[0]
+}
+
+func suffix(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
+
+// Spread returns n evenly spaced, increasing keys of the shortest length
+// that leaves at least one free key between any two of them.
+func Spread(n int) []string {
+	width, space := 1, base
+	for space < 2*int64(n)+1 {
+		width++
+		space *= base
+	}
+	step := space / int64(n+1)
+
+	keys := make([]string, n)
+	buf := make([]byte, width)
+	for i := range keys {
+		v := step * int64(i+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = Digits[v%base]
+			v /= base
+		}
+		keys[i] = strings.TrimRight(string(buf), Digits[:1])
+	}
+	return keys
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// dbError maps errors from the generated queries to a status code. notFound
+// is the message used when the query returned no rows.
+func dbError(c echo.Context, err error, notFound string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: notFound})
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case foreignKeyViolation:
+			switch pgErr.ConstraintName {
+			case "todos_user_id_fkey", "scores_gamer_id_fkey",
+				"friendships_requester_id_fkey", "friendships_addressee_id_fkey":
+				return c.JSON(http.StatusUnprocessableEntity, errorResponse{Error: "gamer does not exist"})
+			case "todo_reminders_todo_id_fkey":
+				return c.JSON(http.StatusNotFound, errorResponse{Error: "todo not found"})
+			}
+			return c.JSON(http.StatusConflict, errorResponse{Error: pgErr.Detail})
+		case uniqueViolation:
+			return c.JSON(http.StatusConflict, errorResponse{Error: pgErr.Detail})
+		}
+	}
+
+	c.Logger().Error(err)
+	return c.JSON(http.StatusInternalServerError, errorResponse{Error: "internal server error"})
+}
+
+func badRequest(c echo.Context, msg string) error {
+	return c.JSON(http.StatusBadRequest, errorResponse{Error: msg})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/notify"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+type EventHandler struct {
+	Queries *db.Queries
+	Hub     *notify.Hub
+}
+
+// StreamTodoEvents streams a gamer's todo changes as server-sent events
+// until the client disconnects.
+func (h *EventHandler) StreamTodoEvents(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	events, unsubscribe := h.Hub.Subscribe(id)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	// stop nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			// comment lines keep proxies from closing an idle connection
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				// the server is shutting down
+				return nil
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", ev.Op, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
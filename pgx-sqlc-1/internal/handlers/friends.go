@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"pgx-sqlc-1/internal/db"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSuggestionDepth = 2
+	maxSuggestionDepth     = 3
+	defaultPathHops        = 4
+	// the walk visits each gamer at most once per hop, so its cost grows
+	// with hops times the gamers within reach; keep it bounded
+	maxPathHops = 6
+)
+
+// FriendHandler acts on behalf of the gamer in the :id path parameter.
+type FriendHandler struct {
+	Queries *db.Queries
+}
+
+type friendRequestRequest struct {
+	GamerID int32 `json:"gamer_id"`
+}
+
+type friendshipResponse struct {
+	RequesterID int32     `json:"requester_id"`
+	AddresseeID int32     `json:"addressee_id"`
+	Status      string    `json:"status"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func newFriendshipResponse(f db.Friendship) friendshipResponse {
+	return friendshipResponse{
+		RequesterID: f.RequesterID,
+		AddresseeID: f.AddresseeID,
+		Status:      f.Status,
+		UpdatedAt:   f.UpdatedAt.Time,
+	}
+}
+
+type friendRequestResponse struct {
+	gamerResponse
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type suggestionResponse struct {
+	gamerResponse
+	Distance      int32 `json:"distance"`
+	MutualFriends int64 `json:"mutual_friends"`
+}
+
+type connectionResponse struct {
+	Hops int             `json:"hops"`
+	Path []gamerResponse `json:"path"`
+}
+
+// gamerPair reads the acting gamer and the other gamer, which come from
+// the path parameter other or, when that's absent, from the request body.
+// A non-empty message means the request is invalid.
+func gamerPair(c echo.Context) (int32, int32, string) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return 0, 0, "invalid gamer id"
+	}
+
+	var other int32
+	if c.Param("other") != "" {
+		if other, err = idParam(c, "other"); err != nil {
+			return 0, 0, "invalid other gamer id"
+		}
+	} else {
+		var req friendRequestRequest
+		if err := c.Bind(&req); err != nil {
+			return 0, 0, "invalid request body"
+		}
+		if req.GamerID < 1 {
+			return 0, 0, "gamer_id is required"
+		}
+		other = req.GamerID
+	}
+
+	if id == other {
+		return 0, 0, "a gamer can't befriend or block themselves"
+	}
+
+	return id, other, ""
+}
+
+// SendFriendRequest asks gamer_id to become friends. If gamer_id had already
+// asked, their request is accepted instead and the status is accepted.
+func (h *FriendHandler) SendFriendRequest(c echo.Context) error {
+	id, other, msg := gamerPair(c)
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	friendship, err := h.Queries.RequestFriendship(c.Request().Context(), db.RequestFriendshipParams{
+		RequesterID: id,
+		AddresseeID: other,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusConflict, errorResponse{Error: "the gamers are already friends, have a pending request or are blocked"})
+	}
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, newFriendshipResponse(friendship))
+}
+
+func (h *FriendHandler) ListFriendRequests(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	rows, err := h.Queries.ListIncomingFriendRequests(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]friendRequestResponse, len(rows))
+	for i, r := range rows {
+		res[i] = friendRequestResponse{
+			gamerResponse: gamerResponse{ID: r.ID, FirstName: r.FirstName, LastName: r.LastName},
+			RequestedAt:   r.RequestedAt.Time,
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// AcceptFriendRequest accepts the pending request other sent to the gamer.
+func (h *FriendHandler) AcceptFriendRequest(c echo.Context) error {
+	id, other, msg := gamerPair(c)
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	friendship, err := h.Queries.AcceptFriendship(c.Request().Context(), db.AcceptFriendshipParams{
+		RequesterID: other,
+		AddresseeID: id,
+	})
+	if err != nil {
+		return dbError(c, err, "friend request not found")
+	}
+
+	return c.JSON(http.StatusOK, newFriendshipResponse(friendship))
+}
+
+// RemoveFriend declines or cancels a request, unfriends, or lifts a block
+// the gamer placed.
+func (h *FriendHandler) RemoveFriend(c echo.Context) error {
+	id, other, msg := gamerPair(c)
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	removed, err := h.Queries.RemoveFriendship(c.Request().Context(), db.RemoveFriendshipParams{
+		GamerID: id,
+		OtherID: other,
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	if removed == 0 {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: "friendship not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BlockGamer replaces any friendship or request with a block. A gamer who
+// is blocked by other can't block back.
+func (h *FriendHandler) BlockGamer(c echo.Context) error {
+	id, other, msg := gamerPair(c)
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	friendship, err := h.Queries.BlockGamer(c.Request().Context(), db.BlockGamerParams{
+		BlockerID: id,
+		BlockedID: other,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusConflict, errorResponse{Error: "the gamer is blocked by the other gamer"})
+	}
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, newFriendshipResponse(friendship))
+}
+
+func (h *FriendHandler) ListFriends(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	friends, err := h.Queries.ListFriends(ctx, id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]gamerResponse, len(friends))
+	for i, g := range friends {
+		res[i] = newGamerResponse(g)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// SuggestFriends lists gamers reachable through friends, closest and with
+// the most friends in common first. Query parameters:
+//
+//	depth  how many hops to walk, 2 (default) or 3
+//	limit  number of suggestions, 1 to 200
+func (h *FriendHandler) SuggestFriends(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	depth := defaultSuggestionDepth
+	if v := c.QueryParam("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > maxSuggestionDepth {
+			return badRequest(c, "depth must be 2 or 3")
+		}
+		depth = n
+	}
+
+	limit := defaultPageSize
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return badRequest(c, "limit must be between 1 and 200")
+		}
+		limit = n
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	rows, err := h.Queries.SuggestFriends(ctx, db.SuggestFriendsParams{
+		GamerID:  id,
+		MaxDepth: int32(depth),
+		PageSize: int32(limit),
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]suggestionResponse, len(rows))
+	for i, r := range rows {
+		res[i] = suggestionResponse{
+			gamerResponse: gamerResponse{ID: r.ID, FirstName: r.FirstName, LastName: r.LastName},
+			Distance:      r.Distance,
+			MutualFriends: r.MutualFriends,
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetConnection finds the shortest chain of friends from the gamer to
+// other, looking at most max_hops (default 4, at most 6) hops away.
+func (h *FriendHandler) GetConnection(c echo.Context) error {
+	id, other, msg := gamerPair(c)
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	maxHops := defaultPathHops
+	if v := c.QueryParam("max_hops"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPathHops {
+			return badRequest(c, "max_hops must be between 1 and 6")
+		}
+		maxHops = n
+	}
+
+	ctx := c.Request().Context()
+
+	path, err := h.Queries.ShortestFriendPath(ctx, db.ShortestFriendPathParams{
+		FromID:  id,
+		ToID:    other,
+		MaxHops: int32(maxHops),
+	})
+	if err != nil {
+		return dbError(c, err, "the gamers aren't connected")
+	}
+
+	gamers, err := h.Queries.GetGamersByIDs(ctx, path)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	byID := make(map[int32]db.Gamer, len(gamers))
+	for _, g := range gamers {
+		byID[g.ID] = g
+	}
+
+	res := connectionResponse{Hops: len(path) - 1, Path: make([]gamerResponse, len(path))}
+	for i, gamerID := range path {
+		res.Path[i] = newGamerResponse(byID[gamerID])
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
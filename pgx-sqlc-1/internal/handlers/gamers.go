@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"net/http"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/service"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+type GamerHandler struct {
+	Queries *db.Queries
+	Service *service.GamerService
+}
+
+type createGamerRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	// Todos are initial tasks, created in the same transaction as the gamer.
+	Todos []string `json:"todos"`
+}
+
+type updateGamerRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type gamerResponse struct {
+	ID        int32  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+func newGamerResponse(g db.Gamer) gamerResponse {
+	return gamerResponse{ID: g.ID, FirstName: g.FirstName, LastName: g.LastName}
+}
+
+type createGamerResponse struct {
+	gamerResponse
+	Todos []todoResponse `json:"todos"`
+}
+
+func (h *GamerHandler) CreateGamer(c echo.Context) error {
+	var req createGamerRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+	if req.FirstName == "" || req.LastName == "" {
+		return badRequest(c, "first_name and last_name are required")
+	}
+	for i, task := range req.Todos {
+		req.Todos[i] = strings.TrimSpace(task)
+		if req.Todos[i] == "" {
+			return badRequest(c, "todos can't be empty")
+		}
+	}
+
+	gamer, todos, err := h.Service.CreateGamerWithTodos(c.Request().Context(), db.CreateGamerParams{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	}, req.Todos)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := createGamerResponse{gamerResponse: newGamerResponse(gamer), Todos: make([]todoResponse, len(todos))}
+	for i, t := range todos {
+		res.Todos[i] = newTodoResponse(t)
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (h *GamerHandler) GetGamer(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	gamer, err := h.Queries.GetGamer(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	return c.JSON(http.StatusOK, newGamerResponse(gamer))
+}
+
+type listGamersResponse struct {
+	Gamers []gamerResponse `json:"gamers"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GetGamers lists gamers with keyset pagination. Query parameters:
+//
+//	sort    id (default), last_name or first_name
+//	q       case insensitive prefix of the first or last name
+//	limit   page size, 1 to 200
+//	cursor  next_cursor from the previous page
+func (h *GamerHandler) GetGamers(c echo.Context) error {
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "id"
+	}
+
+	pageSize := defaultPageSize
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return badRequest(c, "limit must be between 1 and 200")
+		}
+		pageSize = n
+	}
+
+	var after gamerCursor
+	if v := c.QueryParam("cursor"); v != "" {
+		var err error
+		if after, err = decodeGamerCursor(v, sort); err != nil {
+			return badRequest(c, err.Error())
+		}
+	}
+
+	var prefix pgtype.Text
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		prefix = pgtype.Text{String: likePrefix(q), Valid: true}
+	}
+
+	// fetch one extra row to find out whether there is a next page
+	limit := int32(pageSize + 1)
+	hasCursor := c.QueryParam("cursor") != ""
+	ctx := c.Request().Context()
+
+	var (
+		gamers []db.Gamer
+		err    error
+	)
+	switch sort {
+	case "id":
+		gamers, err = h.Queries.ListGamersByID(ctx, db.ListGamersByIDParams{
+			AfterID:    after.ID,
+			NamePrefix: prefix,
+			PageSize:   limit,
+		})
+	case "last_name":
+		gamers, err = h.Queries.ListGamersByLastName(ctx, db.ListGamersByLastNameParams{
+			AfterLastName:  pgtype.Text{String: after.LastName, Valid: hasCursor},
+			AfterFirstName: after.FirstName,
+			AfterID:        after.ID,
+			NamePrefix:     prefix,
+			PageSize:       limit,
+		})
+	case "first_name":
+		gamers, err = h.Queries.ListGamersByFirstName(ctx, db.ListGamersByFirstNameParams{
+			AfterFirstName: pgtype.Text{String: after.FirstName, Valid: hasCursor},
+			AfterLastName:  after.LastName,
+			AfterID:        after.ID,
+			NamePrefix:     prefix,
+			PageSize:       limit,
+		})
+	default:
+		return badRequest(c, "sort must be id, last_name or first_name")
+	}
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := listGamersResponse{Gamers: make([]gamerResponse, 0, pageSize)}
+	if len(gamers) > pageSize {
+		gamers = gamers[:pageSize]
+		last := gamers[len(gamers)-1]
+		res.NextCursor = gamerCursor{
+			Sort:      sort,
+			ID:        last.ID,
+			FirstName: last.FirstName,
+			LastName:  last.LastName,
+		}.encode()
+	}
+	for _, g := range gamers {
+		res.Gamers = append(res.Gamers, newGamerResponse(g))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// idParam parses a serial id path parameter.
+func idParam(c echo.Context, name string) (int32, error) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 32)
+	if err != nil || id < 1 {
+		return 0, echo.ErrBadRequest
+	}
+	return int32(id), nil
+}
+
+func (h *GamerHandler) UpdateGamer(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	var req updateGamerRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+	if req.FirstName == "" || req.LastName == "" {
+		return badRequest(c, "first_name and last_name are required")
+	}
+
+	gamer, err := h.Queries.UpdateGamer(c.Request().Context(), db.UpdateGamerParams{
+		ID:        id,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	})
+	if err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	return c.JSON(http.StatusOK, newGamerResponse(gamer))
+}
+
+// DeleteGamer also removes the gamer's todos, through ON DELETE CASCADE on
+// todos.user_id.
+func (h *GamerHandler) DeleteGamer(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	deleted, err := h.Queries.DeleteGamer(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	if deleted == 0 {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: "gamer not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *GamerHandler) ListGamerTodos(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	ctx := c.Request().Context()
+
+	// an empty list is ambiguous, so check the gamer first to 404 properly
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	todos, err := h.Queries.ListTodosByGamer(ctx, id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]todoResponse, len(todos))
+	for i, t := range todos {
+		res[i] = newTodoResponse(t)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+type todoCountsResponse struct {
+	GamerID int32 `json:"gamer_id"`
+	Open    int64 `json:"open"`
+	Closed  int64 `json:"closed"`
+}
+
+func (h *GamerHandler) GetGamerTodoCounts(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	counts, err := h.Queries.CountTodosByGamer(ctx, id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, todoCountsResponse{GamerID: id, Open: counts.Open, Closed: counts.Closed})
+}
+
+func (h *GamerHandler) GetTodoCounts(c echo.Context) error {
+	rows, err := h.Queries.CountTodosPerGamer(c.Request().Context())
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]todoCountsResponse, len(rows))
+	for i, r := range rows {
+		res[i] = todoCountsResponse{GamerID: r.GamerID, Open: r.Open, Closed: r.Closed}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+	"pgx-sqlc-1/internal/db"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSpread  = 5
+	maxSpread      = 50
+	defaultHistory = 30 * 24 * time.Hour
+)
+
+var leaderboardPeriods = map[string]bool{
+	"daily":    true,
+	"weekly":   true,
+	"all_time": true,
+}
+
+// LeaderboardHandler reads the boards from the leaderboard materialized
+// view, so rankings lag behind new scores until the next refresh.
+type LeaderboardHandler struct {
+	Queries *db.Queries
+}
+
+type submitScoreRequest struct {
+	Points int32 `json:"points"`
+	// PlayedAt defaults to now.
+	PlayedAt *time.Time `json:"played_at"`
+}
+
+type scoreResponse struct {
+	ID       int32     `json:"id"`
+	GamerID  int32     `json:"gamer_id"`
+	Points   int32     `json:"points"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+type leaderboardEntry struct {
+	Rank      int64  `json:"rank"`
+	Position  int64  `json:"position"`
+	GamerID   int32  `json:"gamer_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Points    int64  `json:"points"`
+	Matches   int64  `json:"matches"`
+}
+
+type leaderboardResponse struct {
+	Period      string             `json:"period"`
+	RefreshedAt *time.Time         `json:"refreshed_at"`
+	Entries     []leaderboardEntry `json:"entries"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type rankHistoryEntry struct {
+	Rank    int64     `json:"rank"`
+	Points  int64     `json:"points"`
+	TakenAt time.Time `json:"taken_at"`
+}
+
+// periodParam reads the period query parameter, defaulting to all_time.
+func periodParam(c echo.Context) (string, bool) {
+	period := c.QueryParam("period")
+	if period == "" {
+		period = "all_time"
+	}
+	return period, leaderboardPeriods[period]
+}
+
+// SubmitScore records the result of a match for the gamer.
+func (h *LeaderboardHandler) SubmitScore(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	var req submitScoreRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+	if req.Points < 0 {
+		return badRequest(c, "points can't be negative")
+	}
+
+	arg := db.CreateScoreParams{GamerID: id, Points: req.Points}
+	if req.PlayedAt != nil {
+		if req.PlayedAt.After(time.Now()) {
+			return badRequest(c, "played_at can't be in the future")
+		}
+		arg.PlayedAt = pgtype.Timestamptz{Time: *req.PlayedAt, Valid: true}
+	}
+
+	score, err := h.Queries.CreateScore(c.Request().Context(), arg)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, scoreResponse{
+		ID:       score.ID,
+		GamerID:  score.GamerID,
+		Points:   score.Points,
+		PlayedAt: score.PlayedAt.Time,
+	})
+}
+
+// GetLeaderboard pages through a board from the top. Query parameters:
+//
+//	period  daily, weekly or all_time (default)
+//	limit   page size, 1 to 200
+//	cursor  next_cursor from the previous page
+func (h *LeaderboardHandler) GetLeaderboard(c echo.Context) error {
+	period, ok := periodParam(c)
+	if !ok {
+		return badRequest(c, "period must be daily, weekly or all_time")
+	}
+
+	pageSize := defaultPageSize
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return badRequest(c, "limit must be between 1 and 200")
+		}
+		pageSize = n
+	}
+
+	// positions are unique per board, so the last one seen is the cursor
+	var after int64
+	if v := c.QueryParam("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return badRequest(c, errInvalidCursor.Error())
+		}
+		after = n
+	}
+
+	rows, err := h.Queries.ListLeaderboard(c.Request().Context(), db.ListLeaderboardParams{
+		Period:        period,
+		AfterPosition: after,
+		PageSize:      int32(pageSize + 1),
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := leaderboardResponse{Period: period, Entries: make([]leaderboardEntry, 0, pageSize)}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		res.NextCursor = strconv.FormatInt(rows[len(rows)-1].Position, 10)
+	}
+	for _, r := range rows {
+		res.RefreshedAt = &r.RefreshedAt.Time
+		res.Entries = append(res.Entries, leaderboardEntry{
+			Rank:      r.Rank,
+			Position:  r.Position,
+			GamerID:   r.GamerID,
+			FirstName: r.FirstName,
+			LastName:  r.LastName,
+			Points:    r.Points,
+			Matches:   r.Matches,
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetGamerLeaderboard returns the gamer's entry with up to spread entries
+// (default 5, at most 50) on either side of it.
+func (h *LeaderboardHandler) GetGamerLeaderboard(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	period, ok := periodParam(c)
+	if !ok {
+		return badRequest(c, "period must be daily, weekly or all_time")
+	}
+
+	spread := defaultSpread
+	if v := c.QueryParam("spread"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSpread {
+			return badRequest(c, "spread must be between 0 and 50")
+		}
+		spread = n
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	rows, err := h.Queries.ListLeaderboardAroundGamer(ctx, db.ListLeaderboardAroundGamerParams{
+		Period:  period,
+		GamerID: id,
+		Spread:  int64(spread),
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: "gamer is not ranked on this board"})
+	}
+
+	res := leaderboardResponse{Period: period, RefreshedAt: &rows[0].RefreshedAt.Time, Entries: make([]leaderboardEntry, len(rows))}
+	for i, r := range rows {
+		res.Entries[i] = leaderboardEntry{
+			Rank:      r.Rank,
+			Position:  r.Position,
+			GamerID:   r.GamerID,
+			FirstName: r.FirstName,
+			LastName:  r.LastName,
+			Points:    r.Points,
+			Matches:   r.Matches,
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetRankHistory lists every change of the gamer's rank on a board, oldest
+// first. since is an RFC 3339 time and defaults to 30 days ago.
+func (h *LeaderboardHandler) GetRankHistory(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	period, ok := periodParam(c)
+	if !ok {
+		return badRequest(c, "period must be daily, weekly or all_time")
+	}
+
+	since := time.Now().Add(-defaultHistory)
+	if v := c.QueryParam("since"); v != "" {
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return badRequest(c, "since must be an RFC 3339 time")
+		}
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetGamer(ctx, id); err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	rows, err := h.Queries.ListRankHistory(ctx, db.ListRankHistoryParams{
+		GamerID: id,
+		Period:  period,
+		Since:   pgtype.Timestamptz{Time: since, Valid: true},
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]rankHistoryEntry, len(rows))
+	for i, r := range rows {
+		res[i] = rankHistoryEntry{Rank: r.Rank, Points: r.Points, TakenAt: r.TakenAt.Time}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
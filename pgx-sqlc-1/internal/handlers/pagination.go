@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// gamerCursor is the position of the last gamer on a page. It travels as
+// opaque base64 so clients can't build their own, and it remembers the sort
+// it was made for so it can't be replayed against another ordering.
+type gamerCursor struct {
+	Sort      string `json:"s"`
+	ID        int32  `json:"i"`
+	FirstName string `json:"f,omitempty"`
+	LastName  string `json:"l,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func (c gamerCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeGamerCursor(s, sort string) (gamerCursor, error) {
+	var c gamerCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, errInvalidCursor
+	}
+	if c.Sort != sort {
+		return c, errors.New("cursor was created for a different sort")
+	}
+
+	return c, nil
+}
+
+// likePrefix turns user input into a LIKE pattern matching values that start
+// with it, escaping the characters LIKE treats specially.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(strings.ToLower(s)) + "%"
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"pgx-sqlc-1/internal/service"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const maxSyncRequestChanges = 1000
+
+// SyncHandler serves the delta sync of offline clients.
+type SyncHandler struct {
+	Service *service.SyncService
+}
+
+type syncChangeRequest struct {
+	ID        int32                      `json:"id"`
+	ClientID  pgtype.UUID                `json:"client_id"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	Deleted   bool                       `json:"deleted"`
+	Fields    map[string]json.RawMessage `json:"fields"`
+}
+
+type syncRequest struct {
+	// Token is the token of the last sync, empty on the first one.
+	Token   string              `json:"token"`
+	Changes []syncChangeRequest `json:"changes"`
+}
+
+type syncResultResponse struct {
+	ID       int32       `json:"id,omitempty"`
+	ClientID pgtype.UUID `json:"client_id"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type syncTodoResponse struct {
+	todoResponse
+	ClientID pgtype.UUID `json:"client_id"`
+	Version  int64       `json:"version"`
+}
+
+type syncTombstoneResponse struct {
+	ID       int32       `json:"id"`
+	ClientID pgtype.UUID `json:"client_id"`
+	Version  int64       `json:"version"`
+}
+
+type syncResponse struct {
+	Token string `json:"token"`
+	// HasMore means there are more server changes: sync again with Token.
+	HasMore bool                    `json:"has_more"`
+	Results []syncResultResponse    `json:"results"`
+	Todos   []syncTodoResponse      `json:"todos"`
+	Deleted []syncTombstoneResponse `json:"deleted"`
+}
+
+// Sync applies a batch of offline changes to the gamer's todos and returns
+// every server change since the client's token. Changes are merged per
+// field, the most recent updated_at wins; results[i] tells what happened
+// to changes[i]. Only a broken request fails as a whole.
+func (h *SyncHandler) Sync(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid gamer id")
+	}
+
+	var req syncRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+	if len(req.Changes) > maxSyncRequestChanges {
+		return badRequest(c, "at most 1000 changes per sync")
+	}
+
+	var token int64
+	if req.Token != "" {
+		token, err = strconv.ParseInt(req.Token, 10, 64)
+		if err != nil || token < 0 {
+			return badRequest(c, "invalid token")
+		}
+	}
+
+	changes := make([]service.SyncChange, len(req.Changes))
+	for i, ch := range req.Changes {
+		changes[i] = service.SyncChange{
+			ID:        ch.ID,
+			ClientID:  ch.ClientID,
+			UpdatedAt: ch.UpdatedAt,
+			Deleted:   ch.Deleted,
+			Fields:    ch.Fields,
+		}
+	}
+
+	synced, err := h.Service.Sync(c.Request().Context(), id, token, changes)
+	if err != nil {
+		return dbError(c, err, "gamer not found")
+	}
+
+	res := syncResponse{
+		Token:   strconv.FormatInt(synced.Token, 10),
+		HasMore: synced.HasMore,
+		Results: make([]syncResultResponse, len(synced.Results)),
+		Todos:   make([]syncTodoResponse, len(synced.Todos)),
+		Deleted: make([]syncTombstoneResponse, len(synced.Tombstones)),
+	}
+	for i, r := range synced.Results {
+		res.Results[i] = syncResultResponse{ID: r.ID, ClientID: r.ClientID, Status: r.Status, Error: r.Error}
+	}
+	for i, t := range synced.Todos {
+		res.Todos[i] = syncTodoResponse{todoResponse: newTodoResponse(t), ClientID: t.ClientID, Version: t.Version}
+	}
+	for i, t := range synced.Tombstones {
+		res.Deleted[i] = syncTombstoneResponse{ID: t.TodoID, ClientID: t.ClientID, Version: t.Version}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/recur"
+	"pgx-sqlc-1/internal/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const maxPriority = 3
+
+type TodoHandler struct {
+	Queries *db.Queries
+	Service *service.TodoService
+}
+
+// todoSchedule are the optional scheduling fields shared by create and
+// update requests.
+type todoSchedule struct {
+	DueAt *time.Time `json:"due_at"`
+	// TimeZone is an IANA name such as Europe/Berlin, UTC by default.
+	TimeZone string `json:"time_zone"`
+	// Priority goes from 0 (none, the default) to 3 (high).
+	Priority int16 `json:"priority"`
+	// Recurrence is an RRULE, see package recur. It needs a due date.
+	Recurrence string `json:"recurrence"`
+}
+
+// params validates the schedule and converts it to query parameters. A
+// non-empty message means the schedule is invalid.
+func (s todoSchedule) params() (dueAt pgtype.Timestamptz, timeZone pgtype.Text, priority pgtype.Int2, recurrence pgtype.Text, msg string) {
+	if s.DueAt != nil {
+		dueAt = pgtype.Timestamptz{Time: *s.DueAt, Valid: true}
+	}
+
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			return dueAt, timeZone, priority, recurrence, "unknown time_zone " + s.TimeZone
+		}
+		timeZone = pgtype.Text{String: s.TimeZone, Valid: true}
+	}
+
+	if s.Priority < 0 || s.Priority > maxPriority {
+		return dueAt, timeZone, priority, recurrence, "priority must be between 0 and 3"
+	}
+	priority = pgtype.Int2{Int16: s.Priority, Valid: true}
+
+	if s.Recurrence != "" {
+		rule, err := recur.Parse(s.Recurrence)
+		if err != nil {
+			return dueAt, timeZone, priority, recurrence, err.Error()
+		}
+		if s.DueAt == nil {
+			return dueAt, timeZone, priority, recurrence, "a recurring todo needs a due_at"
+		}
+		recurrence = pgtype.Text{String: rule.String(), Valid: true}
+	}
+
+	return dueAt, timeZone, priority, recurrence, ""
+}
+
+type createTodoRequest struct {
+	UserID int32  `json:"user_id"`
+	Task   string `json:"task"`
+	Done   bool   `json:"done"`
+	todoSchedule
+}
+
+type todoResponse struct {
+	ID         int32      `json:"id"`
+	UserID     int32      `json:"user_id"`
+	Task       string     `json:"task"`
+	Done       bool       `json:"done"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+	TimeZone   string     `json:"time_zone"`
+	Priority   int16      `json:"priority"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	Occurrence int32      `json:"occurrence,omitempty"`
+	// Position sorts the gamer's todos, it is only meaningful for comparing.
+	Position string `json:"position"`
+}
+
+func newTodoResponse(t db.Todo) todoResponse {
+	res := todoResponse{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Task:       t.Task,
+		Done:       t.Done,
+		TimeZone:   t.TimeZone,
+		Priority:   t.Priority,
+		Recurrence: t.Recurrence.String,
+		Position:   t.Position,
+	}
+	if t.DueAt.Valid {
+		res.DueAt = &t.DueAt.Time
+	}
+	if t.Recurrence.Valid {
+		res.Occurrence = t.Occurrence
+	}
+	return res
+}
+
+// todoChangeResponse is returned when a change may have completed a
+// recurring todo.
+type todoChangeResponse struct {
+	todoResponse
+	// NextOccurrence is the todo created because this one was completed.
+	NextOccurrence *todoResponse `json:"next_occurrence,omitempty"`
+}
+
+func newTodoChangeResponse(t db.Todo, next *db.Todo) todoChangeResponse {
+	res := todoChangeResponse{todoResponse: newTodoResponse(t)}
+	if next != nil {
+		n := newTodoResponse(*next)
+		res.NextOccurrence = &n
+	}
+	return res
+}
+
+func (h *TodoHandler) CreateTodo(c echo.Context) error {
+	var req createTodoRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	req.Task = strings.TrimSpace(req.Task)
+	if req.Task == "" {
+		return badRequest(c, "task is required")
+	}
+	if req.UserID < 1 {
+		return badRequest(c, "user_id is required")
+	}
+	dueAt, timeZone, priority, recurrence, msg := req.params()
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	// a missing gamer surfaces as a foreign key violation, which dbError
+	// turns into a 422
+	todo, err := h.Queries.CreateTodo(c.Request().Context(), db.CreateTodoParams{
+		UserID:     req.UserID,
+		Task:       req.Task,
+		Done:       req.Done,
+		DueAt:      dueAt,
+		TimeZone:   timeZone,
+		Priority:   priority,
+		Recurrence: recurrence,
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, newTodoResponse(todo))
+}
+
+// updateTodoRequest replaces the todo, so leaving out a schedule field
+// clears it.
+type updateTodoRequest struct {
+	Task string `json:"task"`
+	Done bool   `json:"done"`
+	todoSchedule
+}
+
+func (h *TodoHandler) GetTodo(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	todo, err := h.Queries.GetTodo(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "todo not found")
+	}
+
+	return c.JSON(http.StatusOK, newTodoResponse(todo))
+}
+
+func (h *TodoHandler) UpdateTodo(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	var req updateTodoRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	req.Task = strings.TrimSpace(req.Task)
+	if req.Task == "" {
+		return badRequest(c, "task is required")
+	}
+	dueAt, timeZone, priority, recurrence, msg := req.params()
+	if msg != "" {
+		return badRequest(c, msg)
+	}
+
+	todo, next, err := h.Service.UpdateTodo(c.Request().Context(), db.UpdateTodoParams{
+		ID:         id,
+		Task:       req.Task,
+		Done:       req.Done,
+		DueAt:      dueAt,
+		TimeZone:   timeZone,
+		Priority:   priority,
+		Recurrence: recurrence,
+	})
+	if err != nil {
+		return dbError(c, err, "todo not found")
+	}
+
+	return c.JSON(http.StatusOK, newTodoChangeResponse(todo, next))
+}
+
+func (h *TodoHandler) ToggleTodo(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	todo, next, err := h.Service.ToggleTodo(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "todo not found")
+	}
+
+	return c.JSON(http.StatusOK, newTodoChangeResponse(todo, next))
+}
+
+func (h *TodoHandler) DeleteTodo(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	deleted, err := h.Queries.DeleteTodo(c.Request().Context(), id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	if deleted == 0 {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: "todo not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// moveTodoRequest names the todos the moved one should end up between.
+// Leaving one out means "directly after after_id" or "directly before
+// before_id"; use before_id of the first todo to move to the top.
+type moveTodoRequest struct {
+	AfterID  *int32 `json:"after_id"`
+	BeforeID *int32 `json:"before_id"`
+}
+
+func (h *TodoHandler) MoveTodo(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	var req moveTodoRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+	if req.AfterID == nil && req.BeforeID == nil {
+		return badRequest(c, "after_id or before_id is required")
+	}
+
+	todo, err := h.Service.MoveTodo(c.Request().Context(), id, req.AfterID, req.BeforeID)
+	if errors.Is(err, service.ErrInvalidMove) {
+		return c.JSON(http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+	}
+	if err != nil {
+		return dbError(c, err, "todo not found")
+	}
+
+	return c.JSON(http.StatusOK, newTodoResponse(todo))
+}
+
+type createReminderRequest struct {
+	RemindAt time.Time `json:"remind_at"`
+}
+
+type reminderResponse struct {
+	ID       int64      `json:"id"`
+	TodoID   int32      `json:"todo_id"`
+	RemindAt time.Time  `json:"remind_at"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+	Attempts int32      `json:"attempts"`
+	// LastError is why the last delivery attempt failed.
+	LastError string `json:"last_error,omitempty"`
+}
+
+func newReminderResponse(r db.TodoReminder) reminderResponse {
+	res := reminderResponse{
+		ID:        r.ID,
+		TodoID:    r.TodoID,
+		RemindAt:  r.RemindAt.Time,
+		Attempts:  r.Attempts,
+		LastError: r.LastError.String,
+	}
+	if r.SentAt.Valid {
+		res.SentAt = &r.SentAt.Time
+	}
+	return res
+}
+
+// CreateReminder adds a reminder to the todo. When a recurring todo is
+// completed its reminders are copied to the next occurrence, shifted by
+// the same amount as the due date.
+func (h *TodoHandler) CreateReminder(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	var req createReminderRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+	if req.RemindAt.IsZero() {
+		return badRequest(c, "remind_at is required")
+	}
+
+	reminder, err := h.Queries.CreateTodoReminder(c.Request().Context(), db.CreateTodoReminderParams{
+		TodoID:   id,
+		RemindAt: pgtype.Timestamptz{Time: req.RemindAt, Valid: true},
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, newReminderResponse(reminder))
+}
+
+func (h *TodoHandler) ListReminders(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := h.Queries.GetTodo(ctx, id); err != nil {
+		return dbError(c, err, "todo not found")
+	}
+
+	reminders, err := h.Queries.ListTodoReminders(ctx, id)
+	if err != nil {
+		return dbError(c, err, "")
+	}
+
+	res := make([]reminderResponse, len(reminders))
+	for i, r := range reminders {
+		res[i] = newReminderResponse(r)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *TodoHandler) DeleteReminder(c echo.Context) error {
+	id, err := idParam(c, "id")
+	if err != nil {
+		return badRequest(c, "invalid todo id")
+	}
+	reminderID, err := strconv.ParseInt(c.Param("reminder_id"), 10, 64)
+	if err != nil || reminderID < 1 {
+		return badRequest(c, "invalid reminder id")
+	}
+
+	deleted, err := h.Queries.DeleteTodoReminder(c.Request().Context(), db.DeleteTodoReminderParams{
+		ID:     reminderID,
+		TodoID: id,
+	})
+	if err != nil {
+		return dbError(c, err, "")
+	}
+	if deleted == 0 {
+		return c.JSON(http.StatusNotFound, errorResponse{Error: "reminder not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
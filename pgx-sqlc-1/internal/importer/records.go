@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// record is one input row, with its values as strings regardless of the
+// input format, and the raw text so a rejected row can be written back out.
+type record struct {
+	line   int
+	raw    string
+	fields map[string]string
+}
+
+type recordReader interface {
+	// next returns io.EOF after the last record. A non-nil record with a
+	// non-nil error is a malformed row that should be rejected, not fatal.
+	next() (*record, error)
+}
+
+func newRecordReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case "csv":
+		return newCSVReader(r)
+	case "ndjson":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, use csv or ndjson", format)
+	}
+}
+
+// csvReader expects a header row naming the columns.
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = false
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv input is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	return &csvReader{r: cr, header: header}, nil
+}
+
+func (c *csvReader) next() (*record, error) {
+	values, err := c.r.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		// FieldPos panics after a failed Read, the parse error has the line
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &record{line: parseErr.Line, raw: strings.Join(values, ",")}, parseErr.Err
+		}
+		return nil, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	rec := &record{line: line, raw: strings.Join(values, ",")}
+
+	if len(values) != len(c.header) {
+		return rec, fmt.Errorf("expected %d columns, got %d", len(c.header), len(values))
+	}
+
+	rec.fields = make(map[string]string, len(values))
+	for i, v := range values {
+		rec.fields[c.header[i]] = v
+	}
+
+	return rec, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) next() (*record, error) {
+	for n.scanner.Scan() {
+		n.line++
+
+		raw := n.scanner.Text()
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		rec := &record{line: n.line, raw: raw}
+
+		var obj map[string]any
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err != nil {
+			return rec, fmt.Errorf("invalid json: %w", err)
+		}
+
+		rec.fields = make(map[string]string, len(obj))
+		for key, value := range obj {
+			switch v := value.(type) {
+			case nil:
+				continue
+			case string:
+				rec.fields[strings.ToLower(key)] = v
+			case json.Number, bool:
+				rec.fields[strings.ToLower(key)] = fmt.Sprint(v)
+			default:
+				return rec, fmt.Errorf("field %q must be a string, number or bool", key)
+			}
+		}
+
+		return rec, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
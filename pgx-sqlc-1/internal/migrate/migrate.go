@@ -0,0 +1,376 @@
+// Package migrate applies the numbered SQL files in an fs.FS to Postgres.
+//
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql.
+// Every applied version is recorded in schema_migrations together with a
+// checksum of its up file. If an applied file is later edited, or removed,
+// every command refuses to run until that is sorted out, because the
+// database no longer matches what the files say it should be.
+//
+// All commands hold a Postgres advisory lock for their whole run, so two
+// deploys migrating at the same time take turns instead of racing.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockID identifies our advisory lock; any constant works as long as nothing
+// else in the database uses it.
+const lockID int64 = 0x6d6967726174 // "migrat"
+
+var filePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateApplied  State = "applied"
+	StateModified State = "modified" // applied, but the file changed since
+	StateMissing  State = "missing"  // applied, but the file is gone
+)
+
+type Status struct {
+	Version   int64
+	Name      string
+	State     State
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// New reads and validates the migrations in fsys.
+func New(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		m := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("version %d is used by %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(data)
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Latest is the highest version known to the migrator, 0 if there are none.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgx.Conn, applied map[int64]appliedRow) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				return revert(ctx, conn, m.migrations[i])
+			}
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until exactly the migrations up to and including
+// version are applied. To(ctx, 0) reverts everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown version %d", version)
+	}
+
+	return m.withLock(ctx, func(conn *pgx.Conn, applied map[int64]appliedRow) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := revert(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := apply(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Baseline records the migrations up to and including version as applied
+// without running them. It adopts a database whose schema was created by
+// hand, or by another tool, before the migrator existed; run it once, then
+// Up applies only what came after. Versions already recorded are left as
+// they are.
+func (m *Migrator) Baseline(ctx context.Context, version int64) error {
+	if m.find(version) == nil {
+		return fmt.Errorf("unknown version %d", version)
+	}
+
+	return m.withLock(ctx, func(conn *pgx.Conn, applied map[int64]appliedRow) error {
+		for v := range applied {
+			if v > version {
+				return fmt.Errorf("version %d is already applied, baseline only adopts a database that is behind %d", v, version)
+			}
+		}
+
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			for _, mig := range m.migrations {
+				if _, ok := applied[mig.Version]; ok || mig.Version > version {
+					continue
+				}
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+					mig.Version, mig.Name, mig.Checksum)
+				if err != nil {
+					return fmt.Errorf("baseline %d_%s: %w", mig.Version, mig.Name, err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Status lists every known migration plus any applied version whose file is
+// gone. It doesn't fail on checksum mismatches, it reports them.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	if err := ensureTable(ctx, conn.Conn()); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name, State: StatePending}
+		if row, ok := applied[mig.Version]; ok {
+			s.State = StateApplied
+			s.AppliedAt = row.AppliedAt
+			if row.Checksum != mig.Checksum {
+				s.State = StateModified
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	for version, row := range applied {
+		if m.find(version) == nil {
+			statuses = append(statuses, Status{Version: version, Name: row.Name, State: StateMissing, AppliedAt: row.AppliedAt})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a single connection holding the advisory lock, after
+// checking that what's applied still matches the files.
+func (m *Migrator) withLock(ctx context.Context, fn func(*pgx.Conn, map[int64]appliedRow) error) error {
+	// advisory locks belong to a session, so everything has to run on the
+	// one connection that took the lock
+	pconn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer pconn.Release()
+	conn := pconn.Conn()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	// read after taking the lock, another process may just have migrated
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	for version, row := range applied {
+		mig := m.find(version)
+		switch {
+		case mig == nil:
+			problems = append(problems, fmt.Errorf("version %d (%s) is applied but its file is missing", version, row.Name))
+		case mig.Checksum != row.Checksum:
+			problems = append(problems, fmt.Errorf("version %d (%s) was edited after it was applied", version, row.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Join(problems...)
+	}
+
+	return fn(conn, applied)
+}
+
+type appliedRow struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func ensureTable(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, conn *pgx.Conn) (map[int64]appliedRow, error) {
+	rows, err := conn.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedRow{}
+	for rows.Next() {
+		var (
+			version int64
+			row     appliedRow
+		)
+		if err := rows.Scan(&version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs the up file and records it in one transaction, so a failing
+// migration leaves neither schema changes nor a schema_migrations row.
+func apply(ctx context.Context, conn *pgx.Conn, mig Migration) error {
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.Up); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			mig.Version, mig.Name, mig.Checksum)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("apply %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func revert(ctx context.Context, conn *pgx.Conn, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("revert %d_%s: no down file", mig.Version, mig.Name)
+	}
+
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("revert %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package notify
+
+import "sync"
+
+// Event is a todo change. The notify_todo_changes trigger only sends Op, ID
+// and UserID, the listener fills in the rest from the todos table. Op is
+// "insert" or "update", or "resync" when events were collapsed (a bulk
+// import), the subscriber fell behind or the listener lost its connection,
+// in which case clients should refetch.
+type Event struct {
+	Op     string `json:"op"`
+	ID     int32  `json:"id,omitempty"`
+	UserID int32  `json:"user_id,omitempty"`
+	Task   string `json:"task,omitempty"`
+	Done   bool   `json:"done"`
+}
+
+const subscriberBuffer = 16
+
+// Hub fans events out to subscribers by gamer id.
+type Hub struct {
+	mu sync.Mutex
+	// subscribers maps each gamer's channels to whether they are lagging,
+	// see send
+	subscribers map[int32]map[chan Event]bool
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[int32]map[chan Event]bool{}}
+}
+
+// Subscribe returns a channel of the gamer's events and a func that must be
+// called to stop receiving them.
+func (h *Hub) Subscribe(gamerID int32) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subscribers[gamerID] == nil {
+		h.subscribers[gamerID] = map[chan Event]bool{}
+	}
+	h.subscribers[gamerID][ch] = false
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			if h.closed {
+				h.mu.Unlock()
+				return
+			}
+			delete(h.subscribers[gamerID], ch)
+			if len(h.subscribers[gamerID]) == 0 {
+				delete(h.subscribers, gamerID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish delivers ev to the subscribers of ev.UserID. A subscriber that
+// falls behind gets a "resync" instead of stalling everyone else, see send.
+func (h *Hub) Publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.subscribers[ev.UserID]
+	for ch, lagged := range subs {
+		subs[ch] = send(ch, lagged, ev)
+	}
+}
+
+// broadcast sends ev to every subscriber regardless of gamer.
+func (h *Hub) broadcast(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subscribers {
+		for ch, lagged := range subs {
+			subs[ch] = send(ch, lagged, ev)
+		}
+	}
+}
+
+// send queues ev on ch and reports whether the subscriber is lagging
+// afterwards. The last slot of the buffer is kept for a resync: once only
+// it is free the subscriber gets "resync" instead of ev, and no events at
+// all until it has read everything, resync included, since refetching
+// covers whatever it missed. Only the hub writes to ch, under h.mu, so the
+// send never blocks.
+func send(ch chan Event, lagged bool, ev Event) bool {
+	if lagged {
+		if len(ch) > 0 {
+			return true
+		}
+		// the resync has been read
+	}
+	if len(ch) >= cap(ch)-1 {
+		ch <- Event{Op: "resync", UserID: ev.UserID}
+		return true
+	}
+	ch <- ev
+	return false
+}
+
+// Close closes every subscriber channel, which ends open event streams. The
+// http server waits for streams on shutdown, so call this first.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for _, subs := range h.subscribers {
+		for ch := range subs {
+			close(ch)
+		}
+	}
+	h.subscribers = nil
+}
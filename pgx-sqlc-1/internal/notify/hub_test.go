@@ -0,0 +1,67 @@
+package notify
+
+import "testing"
+
+func drain(ch <-chan Event) []Event {
+	var evs []Event
+	for {
+		select {
+		case ev := <-ch:
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+func TestPublishResyncsLaggingSubscriber(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+	events, unsubscribe := h.Subscribe(7)
+	defer unsubscribe()
+
+	for i := range subscriberBuffer * 2 {
+		h.Publish(Event{Op: "insert", ID: int32(i + 1), UserID: 7})
+	}
+
+	got := drain(events)
+	if len(got) != subscriberBuffer {
+		t.Fatalf("got %d events, want %d", len(got), subscriberBuffer)
+	}
+	for i, ev := range got[:subscriberBuffer-1] {
+		if ev.Op != "insert" || ev.ID != int32(i+1) {
+			t.Errorf("event %d = %+v, want insert %d", i, ev, i+1)
+		}
+	}
+	if last := got[subscriberBuffer-1]; last.Op != "resync" || last.UserID != 7 {
+		t.Errorf("last event = %+v, want a resync for gamer 7", last)
+	}
+
+	// once the subscriber has caught up it gets events again
+	h.Publish(Event{Op: "update", ID: 99, UserID: 7})
+	got = drain(events)
+	if len(got) != 1 || got[0].Op != "update" || got[0].ID != 99 {
+		t.Errorf("after catching up got %+v, want the update", got)
+	}
+}
+
+func TestPublishHoldsEventsUntilResyncIsRead(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+	events, unsubscribe := h.Subscribe(7)
+	defer unsubscribe()
+
+	for i := range subscriberBuffer {
+		h.Publish(Event{Op: "insert", ID: int32(i + 1), UserID: 7})
+	}
+	// read some, but not the resync at the end
+	for range 3 {
+		<-events
+	}
+	h.Publish(Event{Op: "insert", ID: 100, UserID: 7})
+
+	got := drain(events)
+	if len(got) != subscriberBuffer-3 || got[len(got)-1].Op != "resync" {
+		t.Errorf("got %+v, want the rest of the buffer ending in resync and nothing after", got)
+	}
+}
@@ -0,0 +1,112 @@
+// Package notify turns Postgres NOTIFY messages about todos into per-gamer
+// event streams.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"pgx-sqlc-1/internal/db"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const Channel = "todo_changes"
+
+// Listener holds a dedicated connection, outside the pool, because LISTEN
+// is tied to the session and a pooled connection could be handed to someone
+// else at any time.
+type Listener struct {
+	config *pgx.ConnConfig
+	hub    *Hub
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewListener(config *pgx.ConnConfig, hub *Hub) *Listener {
+	return &Listener{
+		config:     config,
+		hub:        hub,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting with exponential backoff
+// whenever the connection drops. After a reconnect subscribers get a
+// "resync" event, since notifications sent in between are lost.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := l.MinBackoff
+	connected := false
+
+	for {
+		err := l.listen(ctx, func() {
+			if connected {
+				l.hub.broadcast(Event{Op: "resync"})
+			}
+			connected = true
+			backoff = l.MinBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("notify: listener stopped: %v, reconnecting in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > l.MaxBackoff {
+			backoff = l.MaxBackoff
+		}
+	}
+}
+
+// listen connects, issues LISTEN and dispatches notifications until the
+// connection fails. onListening runs once LISTEN has succeeded. The payload
+// only names the todo, so it is read back on the same connection; one
+// deleted in the meantime is skipped.
+func (l *Listener) listen(ctx context.Context, onListening func()) error {
+	conn, err := pgx.ConnectConfig(ctx, l.config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return err
+	}
+	onListening()
+
+	q := db.New(conn)
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			log.Printf("notify: bad payload %q: %v", n.Payload, err)
+			continue
+		}
+
+		if ev.Op != "resync" {
+			todo, err := q.GetTodo(ctx, ev.ID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			ev.Task, ev.Done = todo.Task, todo.Done
+		}
+		l.hub.Publish(ev)
+	}
+}
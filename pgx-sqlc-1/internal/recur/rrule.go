@@ -0,0 +1,231 @@
+// Package recur implements the subset of RFC 5545 recurrence rules todos
+// support:
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY|YEARLY   required
+//	INTERVAL=n                         every n days, weeks, ...
+//	BYDAY=MO,WE,FR                     only with FREQ=WEEKLY, no ordinals
+//	COUNT=n                            n occurrences in total
+//	UNTIL=20250101 or 20250101T090000Z last possible occurrence
+//
+// The start of the series is the todo's due date, so DTSTART isn't needed.
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []time.Weekday
+	// Count is zero for no limit.
+	Count int
+	// Until is zero for no limit.
+	Until time.Time
+}
+
+// Parse reads a rule, with or without the "RRULE:" prefix. Parts outside the
+// supported subset are rejected rather than ignored, so a rule never
+// silently means something else than what the client sent.
+func Parse(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return r, errors.New("recur: empty rule")
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return r, fmt.Errorf("recur: invalid part %q", part)
+		}
+		key = strings.ToUpper(key)
+		if seen[key] {
+			return r, fmt.Errorf("recur: %s given twice", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch f := Frequency(strings.ToUpper(value)); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				return r, fmt.Errorf("recur: unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return r, fmt.Errorf("recur: invalid INTERVAL %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return r, fmt.Errorf("recur: invalid COUNT %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := parseUntil(value)
+			if err != nil {
+				return r, err
+			}
+			r.Until = t
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(value), ",") {
+				day, ok := weekdays[code]
+				if !ok {
+					return r, fmt.Errorf("recur: unsupported BYDAY %q", code)
+				}
+				if !slices.Contains(r.ByDay, day) {
+					r.ByDay = append(r.ByDay, day)
+				}
+			}
+		default:
+			return r, fmt.Errorf("recur: unsupported part %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return r, errors.New("recur: FREQ is required")
+	}
+	if len(r.ByDay) > 0 && r.Freq != Weekly {
+		return r, errors.New("recur: BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return r, errors.New("recur: COUNT and UNTIL can't be combined")
+	}
+	slices.Sort(r.ByDay)
+
+	return r, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	// a date without time includes that whole day
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("recur: invalid UNTIL %q", s)
+}
+
+// String formats the rule in a canonical form, which is what gets stored.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = strings.ToUpper(day.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the occurrence after number occurrence (counting from 1), or
+// false when the series is over. start is the due date of the first
+// occurrence and prev that of occurrence number occurrence, both in the
+// series' location: days are added on the wall clock there, so the time of
+// day survives DST changes. Monthly and yearly rules count from start and
+// clamp to the end of shorter months instead of skipping them, so a series
+// on the 31st falls on the last day of every month rather than sticking to
+// the 28th after February.
+func (r Rule) Next(start, prev time.Time, occurrence int) (time.Time, bool) {
+	if r.Count > 0 && occurrence >= r.Count {
+		return time.Time{}, false
+	}
+
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = prev.AddDate(0, 0, r.Interval)
+	case Weekly:
+		next = r.nextWeekly(start, prev)
+	case Monthly:
+		next = addMonths(start, occurrence*r.Interval)
+	case Yearly:
+		next = addMonths(start, 12*occurrence*r.Interval)
+	default:
+		return time.Time{}, false
+	}
+
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextWeekly finds the next BYDAY day after prev in a week that is a whole
+// number of intervals after the week of start. Weeks start on Monday, as in
+// RFC 5545.
+func (r Rule) nextWeekly(start, prev time.Time) time.Time {
+	if len(r.ByDay) == 0 {
+		return prev.AddDate(0, 0, 7*r.Interval)
+	}
+
+	first := weekStart(start)
+	for d := 1; ; d++ {
+		candidate := prev.AddDate(0, 0, d)
+		weeks := daysBetween(first, weekStart(candidate)) / 7
+		if weeks%r.Interval == 0 && slices.Contains(r.ByDay, candidate.Weekday()) {
+			return candidate
+		}
+	}
+}
+
+func weekStart(t time.Time) time.Time {
+	sinceMonday := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -sinceMonday)
+}
+
+// daysBetween counts calendar days, ignoring DST shifts in between.
+func daysBetween(a, b time.Time) int {
+	ad := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	bd := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(bd.Sub(ad).Hours() / 24)
+}
+
+// addMonths moves t by months, keeping its day of the month or clamping it
+// to the last day of a shorter month.
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
@@ -0,0 +1,117 @@
+package recur
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		rule  string
+		start string
+		// want are the occurrences after start, up to the end of the series
+		// or as many as listed
+		want []string
+		// ends is whether the series is over after want
+		ends bool
+	}{
+		{
+			rule:  "FREQ=MONTHLY",
+			start: "2025-01-31 09:00",
+			want:  []string{"2025-02-28 09:00", "2025-03-31 09:00", "2025-04-30 09:00", "2025-05-31 09:00"},
+		},
+		{
+			rule:  "FREQ=MONTHLY;INTERVAL=2",
+			start: "2024-12-31 09:00",
+			want:  []string{"2025-02-28 09:00", "2025-04-30 09:00", "2025-06-30 09:00", "2025-08-31 09:00"},
+		},
+		{
+			rule:  "FREQ=MONTHLY;INTERVAL=12",
+			start: "2024-02-29 09:00",
+			want:  []string{"2025-02-28 09:00", "2026-02-28 09:00"},
+		},
+		{
+			rule:  "FREQ=YEARLY",
+			start: "2024-02-29 09:00",
+			want:  []string{"2025-02-28 09:00", "2026-02-28 09:00", "2027-02-28 09:00", "2028-02-29 09:00"},
+		},
+		{
+			rule:  "FREQ=DAILY;INTERVAL=3;COUNT=3",
+			start: "2025-01-01 09:00",
+			want:  []string{"2025-01-04 09:00", "2025-01-07 09:00"},
+			ends:  true,
+		},
+		{
+			// the time of day is kept across the switch to summer time
+			rule:  "FREQ=DAILY",
+			start: "2025-03-29 09:00",
+			want:  []string{"2025-03-30 09:00", "2025-03-31 09:00"},
+		},
+		{
+			// a date-only UNTIL includes that day
+			rule:  "FREQ=WEEKLY;UNTIL=20250120",
+			start: "2025-01-06 09:00",
+			want:  []string{"2025-01-13 09:00", "2025-01-20 09:00"},
+			ends:  true,
+		},
+		{
+			rule:  "FREQ=MONTHLY;UNTIL=20250501T000000Z",
+			start: "2025-01-31 09:00",
+			want:  []string{"2025-02-28 09:00", "2025-03-31 09:00", "2025-04-30 09:00"},
+			ends:  true,
+		},
+		{
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			start: "2025-01-06 09:00",
+			want:  []string{"2025-01-09 09:00", "2025-01-20 09:00", "2025-01-23 09:00", "2025-02-03 09:00"},
+		},
+		{
+			rule:  "FREQ=WEEKLY;INTERVAL=3;BYDAY=FR;COUNT=3",
+			start: "2025-01-03 09:00",
+			want:  []string{"2025-01-24 09:00", "2025-02-14 09:00"},
+			ends:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			start := date(tt.start)
+			prev := start
+			for i, want := range tt.want {
+				next, ok := rule.Next(start, prev, i+1)
+				if !ok {
+					t.Fatalf("series ended after %d occurrences, want %s next", i+1, want)
+				}
+				if !next.Equal(date(want)) {
+					t.Fatalf("occurrence %d = %s, want %s", i+2, next.Format("2006-01-02 15:04"), want)
+				}
+				prev = next
+			}
+
+			next, ok := rule.Next(start, prev, len(tt.want)+1)
+			if ok == tt.ends {
+				if tt.ends {
+					t.Errorf("series goes on to %s, want it over", next.Format("2006-01-02 15:04"))
+				} else {
+					t.Errorf("series is over after %d occurrences", len(tt.want)+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package reminder delivers due todo reminders through a Notifier.
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Reminder is what a Notifier gets to work with.
+type Reminder struct {
+	ID       int64      `json:"id"`
+	TodoID   int32      `json:"todo_id"`
+	GamerID  int32      `json:"gamer_id"`
+	Task     string     `json:"task"`
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	TimeZone string     `json:"time_zone"`
+	Priority int16      `json:"priority"`
+	RemindAt time.Time  `json:"remind_at"`
+}
+
+// Notifier delivers a reminder. An error leaves the reminder unsent, so it
+// is retried later; Notify may therefore see the same reminder more than
+// once and should be idempotent on Reminder.ID where that matters.
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// NotifierFunc lets a plain function be used as a Notifier.
+type NotifierFunc func(ctx context.Context, r Reminder) error
+
+func (f NotifierFunc) Notify(ctx context.Context, r Reminder) error {
+	return f(ctx, r)
+}
+
+// LogNotifier only logs reminders, which is enough for development.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n LogNotifier) Notify(_ context.Context, r Reminder) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("reminder: gamer %d, todo %d %q", r.GamerID, r.TodoID, r.Task)
+	return nil
+}
+
+// WebhookNotifier POSTs the reminder as JSON to URL. Any status other than
+// 2xx is a failure.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/service"
+	"time"
+)
+
+// Worker polls for due reminders. Any number of workers, in one process or
+// many, can run against the same database: each batch is leased with
+// FOR UPDATE SKIP LOCKED, so a reminder is only handled by one of them.
+type Worker struct {
+	store    *service.Store
+	notifier Notifier
+
+	Interval    time.Duration
+	BatchSize   int32
+	MaxAttempts int32
+	// SendTimeout bounds a single Notify call.
+	SendTimeout time.Duration
+	// Lease is how long a claimed batch is kept from other workers. It
+	// should cover sending the whole batch, reminders still unsent when it
+	// runs out may be sent twice.
+	Lease time.Duration
+}
+
+func NewWorker(store *service.Store, notifier Notifier) *Worker {
+	return &Worker{
+		store:       store,
+		notifier:    notifier,
+		Interval:    5 * time.Second,
+		BatchSize:   100,
+		MaxAttempts: 5,
+		SendTimeout: 10 * time.Second,
+		Lease:       5 * time.Minute,
+	}
+}
+
+// Run sends reminders until ctx is cancelled. A full batch is followed by
+// the next one right away, otherwise the worker sleeps for Interval.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("reminder: %v", err)
+		}
+
+		if n < int(w.BatchSize) || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.Interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// RunOnce claims one batch and tries to send it, returning how many
+// reminders were claimed. Claiming is a single statement, the reminders are
+// sent with no transaction open and each outcome is recorded on its own, so
+// a slow Notifier holds neither row locks nor a pooled connection.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	q := w.store.Queries(ctx)
+
+	rows, err := q.ClaimDueReminders(ctx, db.ClaimDueRemindersParams{
+		LeaseSeconds: w.Lease.Seconds(),
+		MaxAttempts:  w.MaxAttempts,
+		BatchSize:    w.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		if err := w.send(ctx, q, row); err != nil {
+			return len(rows), err
+		}
+	}
+	return len(rows), nil
+}
+
+// send only returns database errors; a failed delivery is recorded on the
+// reminder and doesn't stop the batch.
+func (w *Worker) send(ctx context.Context, q *db.Queries, row db.ClaimDueRemindersRow) error {
+	r := Reminder{
+		ID:       row.ID,
+		TodoID:   row.TodoID,
+		GamerID:  row.UserID,
+		Task:     row.Task,
+		TimeZone: row.TimeZone,
+		Priority: row.Priority,
+		RemindAt: row.RemindAt.Time,
+	}
+	if row.DueAt.Valid {
+		r.DueAt = &row.DueAt.Time
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, w.SendTimeout)
+	err := w.notifier.Notify(sendCtx, r)
+	cancel()
+
+	if err != nil {
+		log.Printf("reminder: sending %d failed (attempt %d): %v", row.ID, row.Attempts+1, err)
+		return q.MarkReminderFailed(ctx, db.MarkReminderFailedParams{ID: row.ID, LastError: err.Error()})
+	}
+	return q.MarkReminderSent(ctx, row.ID)
+}
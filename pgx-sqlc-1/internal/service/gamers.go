@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"pgx-sqlc-1/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type GamerService struct {
+	store *Store
+}
+
+func NewGamerService(store *Store) *GamerService {
+	return &GamerService{store: store}
+}
+
+// CreateGamerWithTodos creates the gamer and its initial todos atomically:
+// if any todo fails to insert, the gamer isn't created either.
+func (s *GamerService) CreateGamerWithTodos(ctx context.Context, arg db.CreateGamerParams, tasks []string) (db.Gamer, []db.Todo, error) {
+	var (
+		gamer db.Gamer
+		todos []db.Todo
+	)
+
+	err := s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		// reset on every attempt, WithTx may run this more than once
+		todos = make([]db.Todo, 0, len(tasks))
+
+		var err error
+		gamer, err = q.CreateGamer(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			todo, err := q.CreateTodo(ctx, db.CreateTodoParams{UserID: gamer.ID, Task: task})
+			if err != nil {
+				return err
+			}
+			todos = append(todos, todo)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return db.Gamer{}, nil, err
+	}
+
+	return gamer, todos, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"log"
+	"pgx-sqlc-1/internal/db"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type LeaderboardService struct {
+	store *Store
+}
+
+func NewLeaderboardService(store *Store) *LeaderboardService {
+	return &LeaderboardService{store: store}
+}
+
+// Refresh re-ranks every board and records the ranks that changed. The
+// concurrent refresh keeps the old boards readable while the new ones are
+// computed, and the snapshot runs in the same transaction so it sees
+// exactly the refreshed ranks.
+func (s *LeaderboardService) Refresh(ctx context.Context) (int64, error) {
+	var changed int64
+
+	err := s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		if err := q.RefreshLeaderboard(ctx); err != nil {
+			return err
+		}
+
+		var err error
+		changed, err = q.SnapshotRanks(ctx)
+		return err
+	})
+
+	return changed, err
+}
+
+// RefreshEvery refreshes right away and then on every tick until ctx is
+// cancelled. Failures are logged and retried on the next tick.
+func (s *LeaderboardService) RefreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("leaderboard: refresh failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
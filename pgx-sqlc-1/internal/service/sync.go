@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/recur"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// MaxSyncChanges caps how many server changes one sync returns; the client
+// syncs again with the new token while HasMore is set.
+const MaxSyncChanges = 500
+
+// SyncChange is one offline edit of a todo. ID is set for todos the client
+// got from the server, ClientID for todos it created itself (it may also
+// be set for known todos, it is only used to find them).
+type SyncChange struct {
+	ID       int32
+	ClientID pgtype.UUID
+	// UpdatedAt is when the edit happened on the client. Every field in
+	// Fields is compared against the time that field was last written on
+	// the server, and only the newer value survives. Times in the future
+	// are clamped to now, so a wrong clock can't win forever.
+	UpdatedAt time.Time
+	// Deleted deletes the todo, whatever the other fields say.
+	Deleted bool
+	// Fields maps field names (task, done, due_at, time_zone, priority,
+	// recurrence) to their new JSON values; null clears nullable fields.
+	Fields map[string]json.RawMessage
+}
+
+const (
+	SyncApplied  = "applied"  // at least one field or the delete was applied
+	SyncIgnored  = "ignored"  // every field was older than the server's
+	SyncDeleted  = "deleted"  // the todo was deleted before
+	SyncRejected = "rejected" // the change is invalid, see Error
+)
+
+type SyncResult struct {
+	ID       int32
+	ClientID pgtype.UUID
+	Status   string
+	Error    string
+}
+
+type SyncResponse struct {
+	Results []SyncResult
+	// Todos and Tombstones changed after the client's token, including the
+	// client's own changes, in version order.
+	Todos      []db.Todo
+	Tombstones []db.TodoTombstone
+	Token      int64
+	HasMore    bool
+}
+
+// ErrInvalidChange marks a change that can't be applied; it is reported in
+// the change's result rather than failing the sync.
+var ErrInvalidChange = errors.New("invalid change")
+
+var syncedFields = map[string]bool{
+	"task":       true,
+	"done":       true,
+	"due_at":     true,
+	"time_zone":  true,
+	"priority":   true,
+	"recurrence": true,
+}
+
+type SyncService struct {
+	store *Store
+}
+
+func NewSyncService(store *Store) *SyncService {
+	return &SyncService{store: store}
+}
+
+// Sync applies the client's changes and returns what changed on the server
+// since token, in one transaction that holds the gamer's todo lock: no
+// other write for the gamer can slip in between, so the new token covers
+// exactly what the client was sent.
+func (s *SyncService) Sync(ctx context.Context, gamerID int32, token int64, changes []SyncChange) (SyncResponse, error) {
+	var res SyncResponse
+
+	err := s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		res = SyncResponse{Results: make([]SyncResult, len(changes))}
+
+		if _, err := q.GetGamer(ctx, gamerID); err != nil {
+			return err
+		}
+		if err := q.LockTodoOrder(ctx, gamerID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, change := range changes {
+			// each change gets a savepoint, so a rejected one doesn't
+			// undo the others
+			var result SyncResult
+			err := s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+				var err error
+				result, err = applyChange(ctx, q, gamerID, change, now)
+				return err
+			})
+			if errors.Is(err, ErrInvalidChange) || isConstraintViolation(err) {
+				result = SyncResult{ID: change.ID, ClientID: change.ClientID, Status: SyncRejected, Error: err.Error()}
+			} else if err != nil {
+				return err
+			}
+			res.Results[i] = result
+		}
+
+		return s.changesSince(ctx, q, gamerID, token, &res)
+	})
+
+	return res, err
+}
+
+// changesSince merges changed todos and tombstones by version, up to
+// MaxSyncChanges of them.
+func (s *SyncService) changesSince(ctx context.Context, q *db.Queries, gamerID int32, token int64, res *SyncResponse) error {
+	todos, err := q.ListTodosChangedSince(ctx, db.ListTodosChangedSinceParams{
+		UserID:  gamerID,
+		Version: token,
+		MaxRows: MaxSyncChanges + 1,
+	})
+	if err != nil {
+		return err
+	}
+
+	// a client without a token has no todos yet, so nothing to delete
+	var tombstones []db.TodoTombstone
+	if token > 0 {
+		tombstones, err = q.ListTombstonesSince(ctx, db.ListTombstonesSinceParams{
+			UserID:  gamerID,
+			Version: token,
+			MaxRows: MaxSyncChanges + 1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	res.Token = token
+	res.Todos = make([]db.Todo, 0, min(len(todos), MaxSyncChanges))
+	res.Tombstones = make([]db.TodoTombstone, 0, min(len(tombstones), MaxSyncChanges))
+
+	for n := 0; len(todos) > 0 || len(tombstones) > 0; n++ {
+		if n == MaxSyncChanges {
+			res.HasMore = true
+			break
+		}
+		if len(tombstones) == 0 || (len(todos) > 0 && todos[0].Version < tombstones[0].Version) {
+			res.Todos = append(res.Todos, todos[0])
+			res.Token = todos[0].Version
+			todos = todos[1:]
+		} else {
+			res.Tombstones = append(res.Tombstones, tombstones[0])
+			res.Token = tombstones[0].Version
+			tombstones = tombstones[1:]
+		}
+	}
+
+	return nil
+}
+
+func applyChange(ctx context.Context, q *db.Queries, gamerID int32, change SyncChange, now time.Time) (SyncResult, error) {
+	result := SyncResult{ID: change.ID, ClientID: change.ClientID}
+
+	if change.UpdatedAt.IsZero() {
+		return result, fmt.Errorf("%w: updated_at is required", ErrInvalidChange)
+	}
+	if change.UpdatedAt.After(now) {
+		change.UpdatedAt = now
+	}
+	for field := range change.Fields {
+		if !syncedFields[field] {
+			return result, fmt.Errorf("%w: field %s can't be synced", ErrInvalidChange, field)
+		}
+	}
+
+	todo, found, err := findSyncTarget(ctx, q, gamerID, change)
+	if err != nil {
+		return result, err
+	}
+	if !found {
+		if _, err := q.GetTombstone(ctx, db.GetTombstoneParams{
+			UserID:   gamerID,
+			TodoID:   pgtype.Int4{Int32: change.ID, Valid: change.ID != 0},
+			ClientID: change.ClientID,
+		}); err == nil {
+			result.Status = SyncDeleted
+			return result, nil
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return result, err
+		}
+
+		if change.ID != 0 {
+			return result, fmt.Errorf("%w: todo %d not found", ErrInvalidChange, change.ID)
+		}
+		if change.Deleted {
+			// created and deleted offline, the server never needs to know
+			result.Status = SyncIgnored
+			return result, nil
+		}
+		return createSynced(ctx, q, gamerID, change)
+	}
+	result.ID = todo.ID
+
+	if change.Deleted {
+		if _, err := q.DeleteTodo(ctx, todo.ID); err != nil {
+			return result, err
+		}
+		result.Status = SyncApplied
+		return result, nil
+	}
+
+	clocks := map[string]time.Time{}
+	if err := json.Unmarshal(todo.FieldUpdatedAt, &clocks); err != nil {
+		return result, err
+	}
+
+	merged := todo
+	applied := false
+	for field, value := range change.Fields {
+		// on a tie the server keeps its value
+		if !change.UpdatedAt.After(clocks[field]) {
+			continue
+		}
+		if err := setField(&merged, field, value); err != nil {
+			return result, err
+		}
+		clocks[field] = change.UpdatedAt
+		applied = true
+	}
+	if !applied {
+		result.Status = SyncIgnored
+		return result, nil
+	}
+	if err := validateSynced(merged); err != nil {
+		return result, err
+	}
+
+	clockJSON, err := json.Marshal(clocks)
+	if err != nil {
+		return result, err
+	}
+	updated, err := q.UpdateSyncedTodo(ctx, db.UpdateSyncedTodoParams{
+		ID:             todo.ID,
+		Task:           merged.Task,
+		Done:           merged.Done,
+		DueAt:          merged.DueAt,
+		TimeZone:       merged.TimeZone,
+		Priority:       merged.Priority,
+		Recurrence:     merged.Recurrence,
+		FieldUpdatedAt: clockJSON,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// completing a recurring todo offline schedules the next one, as it
+	// does online
+	if !todo.Done && updated.Done {
+		if _, err := scheduleNext(ctx, q, updated); err != nil {
+			return result, err
+		}
+	}
+
+	result.Status = SyncApplied
+	return result, nil
+}
+
+// findSyncTarget looks the todo up by id, or by client id, and locks it.
+func findSyncTarget(ctx context.Context, q *db.Queries, gamerID int32, change SyncChange) (db.Todo, bool, error) {
+	var (
+		todo db.Todo
+		err  error
+	)
+	switch {
+	case change.ID != 0:
+		todo, err = q.GetTodoForUpdate(ctx, change.ID)
+	case change.ClientID.Valid:
+		todo, err = q.GetTodoByClientID(ctx, change.ClientID)
+	default:
+		return todo, false, fmt.Errorf("%w: id or client_id is required", ErrInvalidChange)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return todo, false, nil
+	}
+	if err != nil {
+		return todo, false, err
+	}
+	if todo.UserID != gamerID {
+		return todo, false, fmt.Errorf("%w: todo belongs to another gamer", ErrInvalidChange)
+	}
+	return todo, true, nil
+}
+
+func createSynced(ctx context.Context, q *db.Queries, gamerID int32, change SyncChange) (SyncResult, error) {
+	result := SyncResult{ClientID: change.ClientID}
+
+	todo := db.Todo{TimeZone: "UTC"}
+	clocks := map[string]time.Time{}
+	for field := range syncedFields {
+		clocks[field] = change.UpdatedAt
+	}
+	for field, value := range change.Fields {
+		if err := setField(&todo, field, value); err != nil {
+			return result, err
+		}
+	}
+	if err := validateSynced(todo); err != nil {
+		return result, err
+	}
+
+	clockJSON, err := json.Marshal(clocks)
+	if err != nil {
+		return result, err
+	}
+	created, err := q.CreateSyncedTodo(ctx, db.CreateSyncedTodoParams{
+		UserID:         gamerID,
+		ClientID:       change.ClientID,
+		Task:           todo.Task,
+		Done:           todo.Done,
+		DueAt:          todo.DueAt,
+		TimeZone:       todo.TimeZone,
+		Priority:       todo.Priority,
+		Recurrence:     todo.Recurrence,
+		FieldUpdatedAt: clockJSON,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ID = created.ID
+	result.Status = SyncApplied
+	return result, nil
+}
+
+// setField decodes value into the todo field of that name.
+func setField(todo *db.Todo, field string, value json.RawMessage) error {
+	var err error
+	switch field {
+	case "task":
+		err = json.Unmarshal(value, &todo.Task)
+		todo.Task = strings.TrimSpace(todo.Task)
+	case "done":
+		err = json.Unmarshal(value, &todo.Done)
+	case "priority":
+		err = json.Unmarshal(value, &todo.Priority)
+	case "time_zone":
+		err = json.Unmarshal(value, &todo.TimeZone)
+		if err == nil {
+			_, err = time.LoadLocation(todo.TimeZone)
+		}
+	case "due_at":
+		var t *time.Time
+		err = json.Unmarshal(value, &t)
+		todo.DueAt = pgtype.Timestamptz{}
+		if t != nil {
+			todo.DueAt = pgtype.Timestamptz{Time: *t, Valid: true}
+		}
+	case "recurrence":
+		var rule *string
+		err = json.Unmarshal(value, &rule)
+		todo.Recurrence = pgtype.Text{}
+		if err == nil && rule != nil && *rule != "" {
+			var r recur.Rule
+			if r, err = recur.Parse(*rule); err == nil {
+				todo.Recurrence = pgtype.Text{String: r.String(), Valid: true}
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidChange, field, err)
+	}
+	return nil
+}
+
+// validateSynced checks the merged todo, which can be invalid even when
+// every change on its own was fine.
+func validateSynced(todo db.Todo) error {
+	switch {
+	case todo.Task == "":
+		return fmt.Errorf("%w: task is required", ErrInvalidChange)
+	case todo.Priority < 0 || todo.Priority > 3:
+		return fmt.Errorf("%w: priority must be between 0 and 3", ErrInvalidChange)
+	case todo.Recurrence.Valid && !todo.DueAt.Valid:
+		return fmt.Errorf("%w: a recurring todo needs a due_at", ErrInvalidChange)
+	}
+	return nil
+}
+
+// isConstraintViolation reports postgres integrity errors (class 23), such
+// as a client_id already used by another gamer's todo.
+func isConstraintViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23")
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/fracindex"
+	"pgx-sqlc-1/internal/recur"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type TodoService struct {
+	store *Store
+}
+
+func NewTodoService(store *Store) *TodoService {
+	return &TodoService{store: store}
+}
+
+// UpdateTodo replaces the todo. next is the following occurrence when the
+// update completed a recurring todo, nil otherwise.
+func (s *TodoService) UpdateTodo(ctx context.Context, arg db.UpdateTodoParams) (todo db.Todo, next *db.Todo, err error) {
+	err = s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		// lock the row, so two requests completing the todo at the same time
+		// agree on which one did it
+		old, err := q.GetTodoForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		todo, err = q.UpdateTodo(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		next = nil
+		if !old.Done && todo.Done {
+			next, err = scheduleNext(ctx, q, todo)
+		}
+		return err
+	})
+	return todo, next, err
+}
+
+// ToggleTodo flips done, and creates the next occurrence when that completed
+// a recurring todo.
+func (s *TodoService) ToggleTodo(ctx context.Context, id int32) (todo db.Todo, next *db.Todo, err error) {
+	err = s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		todo, err = q.ToggleTodo(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		next = nil
+		if todo.Done {
+			next, err = scheduleNext(ctx, q, todo)
+		}
+		return err
+	})
+	return todo, next, err
+}
+
+// scheduleNext creates the occurrence after todo, with its reminders moved
+// along. It returns nil when the todo doesn't recur, the series is over or
+// the next occurrence already exists (the todo was completed before).
+func scheduleNext(ctx context.Context, q *db.Queries, todo db.Todo) (*db.Todo, error) {
+	if !todo.Recurrence.Valid || !todo.DueAt.Valid {
+		return nil, nil
+	}
+
+	rule, err := recur.Parse(todo.Recurrence.String)
+	if err != nil {
+		return nil, fmt.Errorf("todo %d: %w", todo.ID, err)
+	}
+	loc, err := time.LoadLocation(todo.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("todo %d: %w", todo.ID, err)
+	}
+
+	// the first occurrence is its own start. A series older than
+	// series_start whose first occurrence is gone goes on from this one,
+	// with what is left of its COUNT.
+	seriesID, seriesStart := todo.SeriesID, todo.SeriesStart
+	start, occurrence := todo.DueAt.Time, int(todo.Occurrence)
+	switch {
+	case !seriesID.Valid:
+		seriesID = pgtype.Int4{Int32: todo.ID, Valid: true}
+		seriesStart = todo.DueAt
+	case seriesStart.Valid:
+		start = seriesStart.Time
+	default:
+		if rule.Count > 0 {
+			rule.Count -= occurrence - 1
+		}
+		occurrence = 1
+	}
+
+	due, ok := rule.Next(start.In(loc), todo.DueAt.Time.In(loc), occurrence)
+	if !ok {
+		return nil, nil
+	}
+
+	next, err := q.CreateNextOccurrence(ctx, db.CreateNextOccurrenceParams{
+		UserID:      todo.UserID,
+		Task:        todo.Task,
+		DueAt:       pgtype.Timestamptz{Time: due, Valid: true},
+		TimeZone:    todo.TimeZone,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    seriesID,
+		SeriesStart: seriesStart,
+		Occurrence:  todo.Occurrence + 1,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.CopyRemindersToOccurrence(ctx, db.CopyRemindersToOccurrenceParams{
+		FromTodoID: todo.ID,
+		FromDueAt:  todo.DueAt,
+		ToTodoID:   next.ID,
+		ToDueAt:    next.DueAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// RebalanceLength is the key length above which a gamer's todos get fresh,
+// evenly spaced positions. GamersWithLongTodoPositions and its partial
+// index have it built in, change all three together.
+const RebalanceLength = 12
+
+// ErrInvalidMove is returned by MoveTodo when the neighbours don't make
+// sense for the todo.
+var ErrInvalidMove = errors.New("invalid move")
+
+// MoveTodo puts the todo right after afterID, right before beforeID, or
+// between both. Only the moved todo's row changes.
+func (s *TodoService) MoveTodo(ctx context.Context, id int32, afterID, beforeID *int32) (db.Todo, error) {
+	var todo db.Todo
+
+	err := s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		var err error
+		todo, err = q.GetTodo(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// positions are only read once the lock is held, so they can't
+		// change before the update
+		if err := q.LockTodoOrder(ctx, todo.UserID); err != nil {
+			return err
+		}
+
+		after, err := s.neighbour(ctx, q, todo, afterID)
+		if err != nil {
+			return err
+		}
+		before, err := s.neighbour(ctx, q, todo, beforeID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case afterID != nil && beforeID == nil:
+			before, err = q.NextTodoPosition(ctx, db.NextTodoPositionParams{UserID: todo.UserID, Position: after, ExcludeID: id})
+		case afterID == nil && beforeID != nil:
+			after, err = q.PrevTodoPosition(ctx, db.PrevTodoPositionParams{UserID: todo.UserID, Position: before, ExcludeID: id})
+		case afterID != nil && beforeID != nil && after >= before:
+			return fmt.Errorf("%w: todo %d is not before todo %d", ErrInvalidMove, *afterID, *beforeID)
+		}
+		// no next or previous todo means the todo goes to the end or start
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		position, err := fracindex.Between(after, before)
+		if err != nil {
+			return err
+		}
+
+		todo, err = q.UpdateTodoPosition(ctx, db.UpdateTodoPositionParams{ID: id, Position: position})
+		return err
+	})
+
+	return todo, err
+}
+
+// neighbour returns the position of the todo with the given id, or "" when
+// id is nil. It must be another todo of the same gamer.
+func (s *TodoService) neighbour(ctx context.Context, q *db.Queries, todo db.Todo, id *int32) (string, error) {
+	if id == nil {
+		return "", nil
+	}
+	if *id == todo.ID {
+		return "", fmt.Errorf("%w: a todo can't be moved next to itself", ErrInvalidMove)
+	}
+
+	other, err := q.GetTodo(ctx, *id)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && other.UserID != todo.UserID) {
+		return "", fmt.Errorf("%w: todo %d is not in the same list", ErrInvalidMove, *id)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return other.Position, nil
+}
+
+// RebalancePositions gives every todo of the gamer a fresh, short position
+// in the current order.
+func (s *TodoService) RebalancePositions(ctx context.Context, gamerID int32) error {
+	return s.store.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, q *db.Queries) error {
+		if err := q.LockTodoOrder(ctx, gamerID); err != nil {
+			return err
+		}
+
+		ids, err := q.ListTodoIDsByPosition(ctx, gamerID)
+		if err != nil {
+			return err
+		}
+
+		return q.SetTodoPositions(ctx, db.SetTodoPositionsParams{
+			UserID:    gamerID,
+			Ids:       ids,
+			Positions: fracindex.Spread(len(ids)),
+		})
+	})
+}
+
+// RebalanceEvery looks for gamers with positions longer than
+// RebalanceLength on every tick and rebalances their lists, until ctx is
+// cancelled.
+func (s *TodoService) RebalanceEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		gamers, err := s.store.Queries(ctx).GamersWithLongTodoPositions(ctx, 100)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("todos: finding long positions failed: %v", err)
+			}
+			continue
+		}
+
+		for _, gamerID := range gamers {
+			if err := s.RebalancePositions(ctx, gamerID); err != nil && ctx.Err() == nil {
+				log.Printf("todos: rebalancing gamer %d failed: %v", gamerID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgx-sqlc-1/internal/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgres error codes that mean "try the whole transaction again"
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// Store runs the generated queries either directly on the pool or inside a
+// transaction started by WithTx.
+type Store struct {
+	pool *pgxpool.Pool
+
+	// Retries is how many extra attempts an outer transaction gets when it
+	// fails with a serialization failure or deadlock.
+	Retries int
+}
+
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool, Retries: 3}
+}
+
+// Queries returns queries bound to the transaction in ctx, or to the pool if
+// there is none.
+func (s *Store) Queries(ctx context.Context) *db.Queries {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return db.New(tx)
+	}
+	return db.New(s.pool)
+}
+
+type txKey struct{}
+
+// TxFunc is the body of a transaction. ctx carries the transaction, so
+// nested WithTx calls and Store.Queries(ctx) use it too.
+type TxFunc func(ctx context.Context, q *db.Queries) error
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling
+// back if it returns an error or panics.
+//
+// When ctx already carries a transaction, WithTx opens a savepoint instead,
+// so a failing inner call only undoes its own work. The caller decides
+// whether that error aborts the outer transaction. Savepoints always run at
+// the outer transaction's isolation level, so opts is ignored for them.
+func (s *Store) WithTx(ctx context.Context, opts pgx.TxOptions, fn TxFunc) error {
+	if outer, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		savepoint, err := outer.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin savepoint: %w", err)
+		}
+		return run(ctx, savepoint, fn)
+	}
+
+	var err error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		var tx pgx.Tx
+		tx, err = s.pool.BeginTx(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+
+		err = run(ctx, tx, fn)
+		if !retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func run(ctx context.Context, tx pgx.Tx, fn TxFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx), db.New(tx)); err != nil {
+		// rollback with a context that is still alive, ctx might be the
+		// reason we failed
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected
+}
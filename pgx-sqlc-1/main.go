@@ -4,11 +4,26 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"pgx-sqlc-1/internal/db"
+	"pgx-sqlc-1/internal/dbtrace"
+	"pgx-sqlc-1/internal/handlers"
+	"pgx-sqlc-1/internal/notify"
+	"pgx-sqlc-1/internal/reminder"
+	"pgx-sqlc-1/internal/service"
+	"time"
+	// time zones of todos must resolve even where the OS has no tz database
+	_ "time/tzdata"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -18,35 +33,155 @@ func main() {
 
 	dbUrl := os.Getenv("DATABASE_URL")
 
+	appPort := os.Getenv("APP_PORT")
+	if appPort == "" {
+		appPort = "8080"
+	}
+
+	slowQueryThreshold := 200 * time.Millisecond
+	if v := os.Getenv("SLOW_QUERY_THRESHOLD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid SLOW_QUERY_THRESHOLD: %v", err)
+		}
+		slowQueryThreshold = d
+	}
+
+	leaderboardRefresh := time.Minute
+	if v := os.Getenv("LEADERBOARD_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid LEADERBOARD_REFRESH_INTERVAL: %q", v)
+		}
+		leaderboardRefresh = d
+	}
+
 	config, err := pgxpool.ParseConfig(dbUrl)
 	if err != nil {
 		log.Fatalf("Failed to parse database URL: %v", err)
 	}
 
+	tracer := dbtrace.NewTracer(slowQueryThreshold)
+	config.ConnConfig.Tracer = tracer
+
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer pool.Close()
 
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(context.Background(), pool, os.Args[2:])
+		case "import":
+			err = runImport(context.Background(), pool, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q, expected migrate or import", os.Args[1])
+		}
+		if err != nil {
+			pool.Close()
+			log.Fatal(err)
+		}
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(tracer.Collectors()...)
+	registry.MustRegister(
+		dbtrace.NewPoolCollector(pool),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
 	queries := db.New(pool)
+	store := service.NewStore(pool)
 
-	createdGamer, err := queries.CreateGamer(context.Background(), db.CreateGamerParams{
-		FirstName: "Tiago",
-		LastName:  "Taron",
-	})
-	if err != nil {
-		log.Fatalf("Failed to create player: %v", err)
-	}
-	fmt.Printf("Created player with ID: %d\n", createdGamer.ID)
+	gamerHandler := handlers.GamerHandler{Queries: queries, Service: service.NewGamerService(store)}
+	todoService := service.NewTodoService(store)
+	todoHandler := handlers.TodoHandler{Queries: queries, Service: todoService}
 
-	gamers, err := queries.GetGamers(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to get players: %v", err)
+	var notifier reminder.Notifier = reminder.LogNotifier{}
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		notifier = reminder.WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
 	}
+	reminderWorker := reminder.NewWorker(store, notifier)
+
+	leaderboardService := service.NewLeaderboardService(store)
+	leaderboardHandler := handlers.LeaderboardHandler{Queries: queries}
+
+	friendHandler := handlers.FriendHandler{Queries: queries}
+	syncHandler := handlers.SyncHandler{Service: service.NewSyncService(store)}
+
+	hub := notify.NewHub()
+	eventHandler := handlers.EventHandler{Queries: queries, Hub: hub}
+
+	e := echo.New()
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	e.GET("/gamers", gamerHandler.GetGamers)
+	e.POST("/gamers", gamerHandler.CreateGamer)
+	e.GET("/gamers/todo-counts", gamerHandler.GetTodoCounts)
+	e.GET("/gamers/:id", gamerHandler.GetGamer)
+	e.PUT("/gamers/:id", gamerHandler.UpdateGamer)
+	e.DELETE("/gamers/:id", gamerHandler.DeleteGamer)
+	e.GET("/gamers/:id/todos", gamerHandler.ListGamerTodos)
+	e.GET("/gamers/:id/todo-counts", gamerHandler.GetGamerTodoCounts)
+	e.GET("/gamers/:id/todos/events", eventHandler.StreamTodoEvents)
+	e.POST("/gamers/:id/sync", syncHandler.Sync)
+	e.POST("/gamers/:id/scores", leaderboardHandler.SubmitScore)
+	e.GET("/gamers/:id/leaderboard", leaderboardHandler.GetGamerLeaderboard)
+	e.GET("/gamers/:id/rank-history", leaderboardHandler.GetRankHistory)
+	e.GET("/gamers/:id/friends", friendHandler.ListFriends)
+	e.DELETE("/gamers/:id/friends/:other", friendHandler.RemoveFriend)
+	e.GET("/gamers/:id/friends/requests", friendHandler.ListFriendRequests)
+	e.POST("/gamers/:id/friends/requests", friendHandler.SendFriendRequest)
+	e.POST("/gamers/:id/friends/requests/:other/accept", friendHandler.AcceptFriendRequest)
+	e.GET("/gamers/:id/friends/suggestions", friendHandler.SuggestFriends)
+	e.PUT("/gamers/:id/blocks/:other", friendHandler.BlockGamer)
+	e.GET("/gamers/:id/connections/:other", friendHandler.GetConnection)
+
+	e.GET("/leaderboard", leaderboardHandler.GetLeaderboard)
+
+	e.POST("/todos", todoHandler.CreateTodo)
+	e.GET("/todos/:id", todoHandler.GetTodo)
+	e.PUT("/todos/:id", todoHandler.UpdateTodo)
+	e.POST("/todos/:id/toggle", todoHandler.ToggleTodo)
+	e.DELETE("/todos/:id", todoHandler.DeleteTodo)
+	e.POST("/todos/:id/move", todoHandler.MoveTodo)
+	e.GET("/todos/:id/reminders", todoHandler.ListReminders)
+	e.POST("/todos/:id/reminders", todoHandler.CreateReminder)
+	e.DELETE("/todos/:id/reminders/:reminder_id", todoHandler.DeleteReminder)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// LISTEN needs its own connection, the pool's settings are reused for it
+	go notify.NewListener(config.ConnConfig.Copy(), hub).Run(ctx)
+
+	go leaderboardService.RefreshEvery(ctx, leaderboardRefresh)
+	go reminderWorker.Run(ctx)
+	go todoService.RebalanceEvery(ctx, time.Minute)
+
+	go func() {
+		err := e.Start(":" + appPort)
+		if err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal("shutting down the server", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	hub.Close()
 
-	for _, gamer := range gamers {
-		fmt.Printf("Player ID: %d, Name: %s %s\n", gamer.ID, gamer.FirstName, gamer.LastName)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		e.Logger.Fatal(err)
 	}
 }
 
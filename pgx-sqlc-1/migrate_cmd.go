@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"pgx-sqlc-1/internal/migrate"
+	"pgx-sqlc-1/migrations"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = `usage: pgx-sqlc-1 migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down          revert the last applied migration
+  status        list migrations and whether they are applied
+  to VERSION    migrate up or down to VERSION, 0 reverts everything
+  baseline VERSION
+                record migrations up to VERSION as applied without running
+                them, for a database whose schema was created by hand`
+
+func runMigrate(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	migrator, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return errors.New("migrate to needs a version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	case "baseline":
+		if len(args) != 2 {
+			return errors.New("migrate baseline needs a version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.Baseline(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := ""
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
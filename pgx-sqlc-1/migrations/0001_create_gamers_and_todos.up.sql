@@ -5,7 +5,8 @@ CREATE TABLE gamers (
 );
 CREATE TABLE todos (
     id SERIAL PRIMARY KEY,
-    user_id SERIAL NOT NULL REFERENCES gamers(id),
+    user_id INTEGER NOT NULL REFERENCES gamers(id) ON DELETE CASCADE,
     task TEXT NOT NULL,
     done BOOLEAN NOT NULL
-);
\ No newline at end of file
+);
+CREATE INDEX todos_user_id_idx ON todos (user_id);
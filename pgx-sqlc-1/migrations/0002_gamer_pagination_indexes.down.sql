@@ -0,0 +1,4 @@
+DROP INDEX gamers_lower_last_name_idx;
+DROP INDEX gamers_lower_first_name_idx;
+DROP INDEX gamers_first_name_last_name_id_idx;
+DROP INDEX gamers_last_name_first_name_id_idx;
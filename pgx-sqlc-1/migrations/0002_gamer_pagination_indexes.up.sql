@@ -0,0 +1,6 @@
+-- keyset pagination walks these in order, id breaks ties between equal names
+CREATE INDEX gamers_last_name_first_name_id_idx ON gamers (last_name, first_name, id);
+CREATE INDEX gamers_first_name_last_name_id_idx ON gamers (first_name, last_name, id);
+-- text_pattern_ops lets LIKE 'prefix%' use the index regardless of collation
+CREATE INDEX gamers_lower_first_name_idx ON gamers (lower(first_name) text_pattern_ops);
+CREATE INDEX gamers_lower_last_name_idx ON gamers (lower(last_name) text_pattern_ops);
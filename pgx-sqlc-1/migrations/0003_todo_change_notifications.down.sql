@@ -0,0 +1,3 @@
+DROP TRIGGER todos_notify_done ON todos;
+DROP TRIGGER todos_notify_insert ON todos;
+DROP FUNCTION notify_todo_change();
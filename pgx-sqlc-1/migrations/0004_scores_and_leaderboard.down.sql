@@ -0,0 +1,3 @@
+DROP TABLE rank_snapshots;
+DROP MATERIALIZED VIEW leaderboard;
+DROP TABLE scores;
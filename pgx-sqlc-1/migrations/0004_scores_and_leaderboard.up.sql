@@ -0,0 +1,61 @@
+CREATE TABLE scores (
+    id SERIAL PRIMARY KEY,
+    gamer_id INTEGER NOT NULL REFERENCES gamers(id) ON DELETE CASCADE,
+    points INTEGER NOT NULL CHECK (points >= 0),
+    played_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX scores_played_at_idx ON scores (played_at);
+CREATE INDEX scores_gamer_id_played_at_idx ON scores (gamer_id, played_at);
+-- every board is ranked once per refresh instead of on every read. daily and
+-- weekly are relative to the time of the refresh. rank gives ties the same
+-- rank, position is unique per board so "around me" windows are stable.
+-- gamers deleted since the last refresh are filtered out by joining gamers.
+CREATE MATERIALIZED VIEW leaderboard AS
+WITH periods AS (
+    SELECT 'daily'::text AS period,
+        date_trunc('day', now()) AS since
+    UNION ALL
+    SELECT 'weekly',
+        date_trunc('week', now())
+    UNION ALL
+    SELECT 'all_time',
+        '-infinity'::timestamptz
+),
+totals AS (
+    SELECT p.period,
+        s.gamer_id,
+        sum(s.points)::bigint AS points,
+        count(*) AS matches
+    FROM periods p
+        JOIN scores s ON s.played_at >= p.since
+    GROUP BY p.period,
+        s.gamer_id
+)
+SELECT period,
+    gamer_id,
+    points,
+    matches,
+    rank() OVER (
+        PARTITION BY period
+        ORDER BY points DESC
+    ) AS rank,
+    row_number() OVER (
+        PARTITION BY period
+        ORDER BY points DESC,
+            gamer_id
+    ) AS position,
+    now()::timestamptz AS refreshed_at
+FROM totals;
+-- REFRESH ... CONCURRENTLY needs a unique index covering every row
+CREATE UNIQUE INDEX leaderboard_period_gamer_id_idx ON leaderboard (period, gamer_id);
+CREATE INDEX leaderboard_period_position_idx ON leaderboard (period, position);
+-- a row is added whenever a refresh changes a gamer's rank or points
+CREATE TABLE rank_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    gamer_id INTEGER NOT NULL REFERENCES gamers(id) ON DELETE CASCADE,
+    period TEXT NOT NULL,
+    rank BIGINT NOT NULL,
+    points BIGINT NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX rank_snapshots_gamer_id_period_taken_at_idx ON rank_snapshots (gamer_id, period, taken_at);
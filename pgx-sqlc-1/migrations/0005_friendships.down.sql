@@ -0,0 +1,2 @@
+DROP VIEW friend_edges;
+DROP TABLE friendships;
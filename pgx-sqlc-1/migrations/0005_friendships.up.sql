@@ -0,0 +1,29 @@
+-- one row per pair of gamers. requester_id is who sent the request, or who
+-- blocked the other one when the status is blocked.
+CREATE TABLE friendships (
+    requester_id INTEGER NOT NULL REFERENCES gamers(id) ON DELETE CASCADE,
+    addressee_id INTEGER NOT NULL REFERENCES gamers(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'blocked')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (requester_id, addressee_id),
+    CHECK (requester_id <> addressee_id)
+);
+-- a request from b to a while a to b exists would otherwise be a second row
+CREATE UNIQUE INDEX friendships_pair_idx ON friendships (
+    least(requester_id, addressee_id),
+    greatest(requester_id, addressee_id)
+);
+CREATE INDEX friendships_addressee_id_idx ON friendships (addressee_id, status);
+-- accepted friendships in both directions, so graph walks only follow
+-- gamer_id -> friend_id
+CREATE VIEW friend_edges AS
+SELECT requester_id AS gamer_id,
+    addressee_id AS friend_id
+FROM friendships
+WHERE status = 'accepted'
+UNION ALL
+SELECT addressee_id,
+    requester_id
+FROM friendships
+WHERE status = 'accepted';
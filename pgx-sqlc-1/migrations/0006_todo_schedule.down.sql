@@ -0,0 +1,10 @@
+DROP TABLE todo_reminders;
+DROP INDEX todos_user_id_due_at_idx;
+DROP INDEX todos_series_id_occurrence_idx;
+ALTER TABLE todos DROP CONSTRAINT todos_recurrence_needs_due_at,
+    DROP COLUMN occurrence,
+    DROP COLUMN series_id,
+    DROP COLUMN recurrence,
+    DROP COLUMN priority,
+    DROP COLUMN time_zone,
+    DROP COLUMN due_at;
@@ -0,0 +1,32 @@
+-- due_at is an instant, time_zone is where the gamer lives: recurrences are
+-- computed on the wall clock there so a 9:00 todo stays at 9:00 across DST.
+-- recurrence is an RRULE, see internal/recur for the supported subset.
+-- occurrences of a recurring todo share series_id, the id of the first one.
+ALTER TABLE todos
+ADD COLUMN due_at TIMESTAMPTZ,
+    ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC',
+    ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3),
+    ADD COLUMN recurrence TEXT,
+    ADD COLUMN series_id INTEGER,
+    ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 1,
+    ADD CONSTRAINT todos_recurrence_needs_due_at CHECK (
+        recurrence IS NULL
+        OR due_at IS NOT NULL
+    );
+-- completing an occurrence twice (done, undone, done) must not create the
+-- next one twice
+CREATE UNIQUE INDEX todos_series_id_occurrence_idx ON todos (series_id, occurrence);
+CREATE INDEX todos_user_id_due_at_idx ON todos (user_id, due_at)
+WHERE NOT done;
+CREATE TABLE todo_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    remind_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    UNIQUE (todo_id, remind_at)
+);
+-- the reminder worker only ever looks at unsent reminders
+CREATE INDEX todo_reminders_pending_idx ON todo_reminders (remind_at)
+WHERE sent_at IS NULL;
@@ -0,0 +1,5 @@
+DROP TRIGGER todos_assign_position ON todos;
+DROP FUNCTION todos_assign_position();
+DROP FUNCTION todo_position_after(TEXT);
+ALTER TABLE todos DROP CONSTRAINT todos_user_id_position_key,
+    DROP COLUMN position;
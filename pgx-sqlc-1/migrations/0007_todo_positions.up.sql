@@ -0,0 +1,59 @@
+-- position is a fractional index key, see internal/fracindex. "C" collation
+-- makes the keys compare byte by byte, whatever the database locale.
+ALTER TABLE todos
+ADD COLUMN position TEXT COLLATE "C";
+-- existing todos keep their id order, as 8 digit hex keys (a subset of the
+-- base 62 digits) without trailing zeros
+UPDATE todos t
+SET position = rtrim(lpad(to_hex(o.n), 8, '0'), '0')
+FROM (
+        SELECT id,
+            row_number() OVER (
+                PARTITION BY user_id
+                ORDER BY id
+            ) AS n
+        FROM todos
+    ) o
+WHERE o.id = t.id;
+ALTER TABLE todos
+ALTER COLUMN position
+SET NOT NULL;
+-- deferrable so a rebalance can rewrite every key of a gamer in one
+-- statement, even if keys are swapped along the way
+ALTER TABLE todos
+ADD CONSTRAINT todos_user_id_position_key UNIQUE (user_id, position) DEFERRABLE INITIALLY IMMEDIATE;
+-- returns a key greater than key, short and without trailing zeros: the
+-- first digit that isn't the largest one is incremented and the rest
+-- dropped
+CREATE FUNCTION todo_position_after(key TEXT) RETURNS TEXT AS $$
+DECLARE digits CONSTANT TEXT := '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz';
+BEGIN
+    IF key IS NULL OR key = '' THEN
+        RETURN 'V';
+    END IF;
+    FOR i IN 1..length(key) LOOP
+        IF substr(key, i, 1) <> 'z' THEN
+            RETURN substr(key, 1, i - 1) || substr(digits, strpos(digits, substr(key, i, 1)) + 1, 1);
+        END IF;
+    END LOOP;
+    RETURN key || 'V';
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+-- new todos, however they are inserted (API, COPY, recurrences), go to the
+-- end of their gamer's list. The advisory lock is per gamer and the same one
+-- moves and rebalances take, so two inserts can't pick the same key.
+CREATE FUNCTION todos_assign_position() RETURNS trigger AS $$
+BEGIN
+    IF NEW.position IS NULL THEN
+        PERFORM pg_advisory_xact_lock('todos'::regclass::oid::int, NEW.user_id);
+        NEW.position := todo_position_after((
+            SELECT max(position)
+            FROM todos
+            WHERE user_id = NEW.user_id
+        ));
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_assign_position BEFORE
+INSERT ON todos FOR EACH ROW EXECUTE FUNCTION todos_assign_position();
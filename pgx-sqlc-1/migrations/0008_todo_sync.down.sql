@@ -0,0 +1,9 @@
+DROP TRIGGER todos_record_tombstone ON todos;
+DROP FUNCTION todos_record_tombstone();
+DROP TRIGGER todos_track_changes ON todos;
+DROP FUNCTION todos_track_changes();
+DROP TABLE todo_tombstones;
+ALTER TABLE todos DROP COLUMN field_updated_at,
+    DROP COLUMN client_id,
+    DROP COLUMN version;
+DROP SEQUENCE todo_versions;
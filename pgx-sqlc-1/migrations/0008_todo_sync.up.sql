@@ -0,0 +1,71 @@
+-- every write to a todo gets the next version from this sequence; a sync
+-- token is the highest version a client has seen
+CREATE SEQUENCE todo_versions;
+-- client_id is assigned by clients to todos created offline, so a retried
+-- sync doesn't create them twice. field_updated_at holds the time each
+-- synced field was last written, for last-writer-wins merges.
+ALTER TABLE todos
+ADD COLUMN version BIGINT,
+    ADD COLUMN client_id UUID UNIQUE,
+    ADD COLUMN field_updated_at JSONB NOT NULL DEFAULT '{}';
+UPDATE todos
+SET version = nextval('todo_versions');
+ALTER TABLE todos
+ALTER COLUMN version
+SET NOT NULL;
+CREATE INDEX todos_user_id_version_idx ON todos (user_id, version);
+-- deleted todos, so clients learn about deletes. No foreign key to gamers:
+-- deleting a gamer deletes its todos, which adds tombstones for it.
+CREATE TABLE todo_tombstones (
+    todo_id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    client_id UUID,
+    version BIGINT NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX todo_tombstones_user_id_version_idx ON todo_tombstones (user_id, version);
+CREATE INDEX todo_tombstones_client_id_idx ON todo_tombstones (client_id);
+-- versions are handed out under the gamer's advisory lock (the one the
+-- position trigger takes), so they commit in order per gamer and a reader
+-- can never see version n while n-1 is still in flight.
+--
+-- fields changed by a statement that didn't set their clock itself (the
+-- REST endpoints, recurrences, rebalancing) are stamped with now()
+CREATE FUNCTION todos_track_changes() RETURNS trigger AS $$
+DECLARE
+    field TEXT;
+    new_row JSONB := to_jsonb(NEW);
+    old_row JSONB;
+BEGIN
+    PERFORM pg_advisory_xact_lock('todos'::regclass::oid::int, NEW.user_id);
+    NEW.version := nextval('todo_versions');
+    IF TG_OP = 'UPDATE' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    FOREACH field IN ARRAY ARRAY ['task', 'done', 'due_at', 'time_zone', 'priority', 'recurrence', 'position'] LOOP
+        IF TG_OP = 'INSERT' THEN
+            IF NOT NEW.field_updated_at ? field THEN
+                NEW.field_updated_at := jsonb_set(NEW.field_updated_at, ARRAY [field], to_jsonb(now()));
+            END IF;
+        ELSIF (new_row->field) IS DISTINCT FROM (old_row->field)
+        AND (NEW.field_updated_at->field) IS NOT DISTINCT FROM (OLD.field_updated_at->field) THEN
+            NEW.field_updated_at := jsonb_set(NEW.field_updated_at, ARRAY [field], to_jsonb(now()));
+        END IF;
+    END LOOP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_track_changes BEFORE
+INSERT
+    OR
+UPDATE ON todos FOR EACH ROW EXECUTE FUNCTION todos_track_changes();
+CREATE FUNCTION todos_record_tombstone() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock('todos'::regclass::oid::int, OLD.user_id);
+    INSERT INTO todo_tombstones (todo_id, user_id, client_id, version)
+    VALUES (OLD.id, OLD.user_id, OLD.client_id, nextval('todo_versions'));
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_record_tombstone
+AFTER DELETE ON todos FOR EACH ROW EXECUTE FUNCTION todos_record_tombstone();
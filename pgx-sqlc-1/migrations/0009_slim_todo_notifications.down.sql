@@ -0,0 +1,28 @@
+DROP TRIGGER todos_notify_done ON todos;
+DROP TRIGGER todos_notify_insert ON todos;
+DROP FUNCTION notify_todo_done_changes();
+DROP FUNCTION notify_todo_inserts();
+DROP FUNCTION notify_todo_changes(TEXT, INTEGER [], INTEGER []);
+CREATE FUNCTION notify_todo_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify(
+        'todo_changes',
+        json_build_object(
+            'op', lower(TG_OP),
+            'id', NEW.id,
+            'user_id', NEW.user_id,
+            'task', NEW.task,
+            'done', NEW.done
+        )::text
+    );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_notify_insert
+AFTER INSERT ON todos
+FOR EACH ROW EXECUTE FUNCTION notify_todo_change();
+CREATE TRIGGER todos_notify_done
+AFTER UPDATE OF done ON todos
+FOR EACH ROW
+WHEN (OLD.done IS DISTINCT FROM NEW.done)
+EXECUTE FUNCTION notify_todo_change();
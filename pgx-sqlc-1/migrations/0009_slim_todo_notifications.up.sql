@@ -0,0 +1,53 @@
+-- notifications carry only what the listener needs to route the event and
+-- fetch the todo: pg_notify fails the whole statement once a payload goes
+-- over 8000 bytes, which a long task did. The triggers fire per statement,
+-- and one touching more than 100 todos (a bulk import) sends one "resync"
+-- per gamer instead of a notification per row.
+DROP TRIGGER todos_notify_done ON todos;
+DROP TRIGGER todos_notify_insert ON todos;
+DROP FUNCTION notify_todo_change();
+CREATE FUNCTION notify_todo_changes(op TEXT, ids INTEGER [], user_ids INTEGER []) RETURNS void AS $$
+BEGIN
+    IF cardinality(ids) > 100 THEN
+        PERFORM pg_notify(
+            'todo_changes',
+            json_build_object('op', 'resync', 'user_id', g.user_id)::text
+        )
+        FROM (
+                SELECT DISTINCT unnest(user_ids) AS user_id
+            ) g;
+    ELSE
+        PERFORM pg_notify(
+            'todo_changes',
+            json_build_object('op', op, 'id', c.id, 'user_id', c.user_id)::text
+        )
+        FROM unnest(ids, user_ids) AS c(id, user_id);
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+CREATE FUNCTION notify_todo_inserts() RETURNS trigger AS $$
+BEGIN
+    PERFORM notify_todo_changes('insert', array_agg(id ORDER BY id), array_agg(user_id ORDER BY id))
+    FROM inserted;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- transition tables can't be combined with UPDATE OF done, so every update
+-- statement compares the old and new rows itself
+CREATE FUNCTION notify_todo_done_changes() RETURNS trigger AS $$
+BEGIN
+    PERFORM notify_todo_changes('update', array_agg(n.id ORDER BY n.id), array_agg(n.user_id ORDER BY n.id))
+    FROM new_rows n
+        JOIN old_rows o ON o.id = n.id
+    WHERE o.done IS DISTINCT FROM n.done;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_notify_insert
+AFTER INSERT ON todos
+REFERENCING NEW TABLE AS inserted
+FOR EACH STATEMENT EXECUTE FUNCTION notify_todo_inserts();
+CREATE TRIGGER todos_notify_done
+AFTER UPDATE ON todos
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION notify_todo_done_changes();
@@ -0,0 +1 @@
+ALTER TABLE todo_reminders DROP COLUMN claimed_until;
@@ -0,0 +1,5 @@
+-- the reminder worker leases a batch and sends it outside any transaction,
+-- so a slow webhook doesn't hold row locks and a connection. A reminder
+-- whose lease ran out (its worker died mid batch) can be claimed again.
+ALTER TABLE todo_reminders
+ADD COLUMN claimed_until TIMESTAMPTZ;
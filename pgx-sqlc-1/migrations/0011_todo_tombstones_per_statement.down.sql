@@ -0,0 +1,12 @@
+DROP TRIGGER todos_record_tombstones ON todos;
+DROP FUNCTION todos_record_tombstones();
+CREATE FUNCTION todos_record_tombstone() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock('todos'::regclass::oid::int, OLD.user_id);
+    INSERT INTO todo_tombstones (todo_id, user_id, client_id, version)
+    VALUES (OLD.id, OLD.user_id, OLD.client_id, nextval('todo_versions'));
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_record_tombstone
+AFTER DELETE ON todos FOR EACH ROW EXECUTE FUNCTION todos_record_tombstone();
@@ -0,0 +1,37 @@
+-- tombstones are written once per statement: the gamers of the deleted
+-- todos are locked once each, in id order so two deletes spanning the same
+-- gamers can't deadlock, and the tombstones go in with a single insert.
+--
+-- todos_track_changes has to stay a row trigger to stamp NEW.version. Taking
+-- a lock the transaction already holds doesn't use another lock table slot,
+-- so it costs one per distinct gamer too; bulk writes keep that bounded by
+-- committing in batches, see internal/importer.
+DROP TRIGGER todos_record_tombstone ON todos;
+DROP FUNCTION todos_record_tombstone();
+CREATE FUNCTION todos_record_tombstones() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock('todos'::regclass::oid::int, g.user_id)
+    FROM (
+            SELECT DISTINCT user_id
+            FROM deleted
+            ORDER BY user_id
+        ) g;
+    INSERT INTO todo_tombstones (todo_id, user_id, client_id, version)
+    SELECT id,
+        user_id,
+        client_id,
+        nextval('todo_versions')
+    FROM (
+            SELECT id,
+                user_id,
+                client_id
+            FROM deleted
+            ORDER BY id
+        ) d;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER todos_record_tombstones
+AFTER DELETE ON todos
+REFERENCING OLD TABLE AS deleted
+FOR EACH STATEMENT EXECUTE FUNCTION todos_record_tombstones();
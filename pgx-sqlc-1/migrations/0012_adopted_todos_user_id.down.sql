@@ -0,0 +1 @@
+-- nothing to undo, 0012 only makes an adopted schema match 0001
@@ -0,0 +1,11 @@
+-- databases built by hand from the old schema.sql and adopted with
+-- "migrate baseline 1" have todos.user_id SERIAL REFERENCES gamers(id): a
+-- default from its own sequence, a foreign key that blocks deleting a gamer
+-- and no index. Bring them in line with 0001; on a database that ran 0001
+-- this only rebuilds the same foreign key.
+ALTER TABLE todos
+ALTER COLUMN user_id DROP DEFAULT;
+DROP SEQUENCE IF EXISTS todos_user_id_seq;
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_user_id_fkey,
+    ADD CONSTRAINT todos_user_id_fkey FOREIGN KEY (user_id) REFERENCES gamers(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS todos_user_id_idx ON todos (user_id);
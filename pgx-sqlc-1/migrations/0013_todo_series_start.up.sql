@@ -0,0 +1,11 @@
+-- the due date of the first occurrence of a series, NULL on that first
+-- occurrence itself like series_id. Monthly and yearly rules count from it,
+-- so an occurrence clamped to the end of a short month doesn't pull the
+-- rest of the series with it. Series whose first occurrence is already
+-- deleted stay NULL and go on from their latest occurrence.
+ALTER TABLE todos
+ADD COLUMN series_start TIMESTAMPTZ;
+UPDATE todos t
+SET series_start = f.due_at
+FROM todos f
+WHERE f.id = t.series_id;
@@ -0,0 +1 @@
+DROP INDEX todos_long_position_user_id_idx;
@@ -0,0 +1,5 @@
+-- the rebalancer looks for gamers with keys longer than 12 characters every
+-- minute; only those rows are in this index, so the lookup doesn't scan
+-- every todo. The query's predicate has to match this one exactly.
+CREATE INDEX todos_long_position_user_id_idx ON todos (user_id)
+WHERE length(position) > 12;
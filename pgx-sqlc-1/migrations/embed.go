@@ -0,0 +1,9 @@
+// Package migrations holds the schema as numbered up/down SQL files, compiled
+// into the binary. sqlc reads the same directory (it skips the .down.sql
+// files), so the generated code always matches what the migrations build.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -1,18 +1,552 @@
 -- name: GetGamers :many
 SELECT *
 FROM gamers;
+-- name: ListGamersByID :many
+SELECT *
+FROM gamers
+WHERE id > sqlc.arg(after_id)::int
+    AND (
+        sqlc.narg(name_prefix)::text IS NULL
+        OR lower(first_name) LIKE sqlc.narg(name_prefix)
+        OR lower(last_name) LIKE sqlc.narg(name_prefix)
+    )
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+-- name: ListGamersByLastName :many
+SELECT *
+FROM gamers
+WHERE (
+        sqlc.narg(after_last_name)::text IS NULL
+        OR (last_name, first_name, id) > (
+            sqlc.narg(after_last_name)::text,
+            sqlc.arg(after_first_name)::text,
+            sqlc.arg(after_id)::int
+        )
+    )
+    AND (
+        sqlc.narg(name_prefix)::text IS NULL
+        OR lower(first_name) LIKE sqlc.narg(name_prefix)
+        OR lower(last_name) LIKE sqlc.narg(name_prefix)
+    )
+ORDER BY last_name,
+    first_name,
+    id
+LIMIT sqlc.arg(page_size);
+-- name: ListGamersByFirstName :many
+SELECT *
+FROM gamers
+WHERE (
+        sqlc.narg(after_first_name)::text IS NULL
+        OR (first_name, last_name, id) > (
+            sqlc.narg(after_first_name)::text,
+            sqlc.arg(after_last_name)::text,
+            sqlc.arg(after_id)::int
+        )
+    )
+    AND (
+        sqlc.narg(name_prefix)::text IS NULL
+        OR lower(first_name) LIKE sqlc.narg(name_prefix)
+        OR lower(last_name) LIKE sqlc.narg(name_prefix)
+    )
+ORDER BY first_name,
+    last_name,
+    id
+LIMIT sqlc.arg(page_size);
 -- name: GetGamer :one
 SELECT *
 FROM gamers
 WHERE id = $1;
--- name: deleteGamer :exec
+-- name: DeleteGamer :execrows
 DELETE FROM gamers
-Where id = $1;
+WHERE id = $1;
 -- name: CreateGamer :one
 INSERT INTO gamers (first_name, last_name)
 VALUES ($1, $2)
 RETURNING *;
+-- name: UpdateGamer :one
+UPDATE gamers
+SET first_name = $2,
+    last_name = $3
+WHERE id = $1
+RETURNING *;
 -- name: CreateTodo :one
+INSERT INTO todos (
+        user_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence
+    )
+VALUES (
+        sqlc.arg(user_id),
+        sqlc.arg(task),
+        sqlc.arg(done),
+        sqlc.narg(due_at),
+        coalesce(sqlc.narg(time_zone)::text, 'UTC'),
+        coalesce(sqlc.narg(priority)::smallint, 0),
+        sqlc.narg(recurrence)
+    )
+RETURNING *;
+-- name: GetTodo :one
+SELECT *
+FROM todos
+WHERE id = $1;
+-- name: ListTodosByGamer :many
+SELECT *
+FROM todos
+WHERE user_id = $1
+ORDER BY position;
+-- name: GetTodoForUpdate :one
+SELECT *
+FROM todos
+WHERE id = $1 FOR
+UPDATE;
+-- name: UpdateTodo :one
+UPDATE todos
+SET task = sqlc.arg(task),
+    done = sqlc.arg(done),
+    due_at = sqlc.narg(due_at),
+    time_zone = coalesce(sqlc.narg(time_zone)::text, 'UTC'),
+    priority = coalesce(sqlc.narg(priority)::smallint, 0),
+    recurrence = sqlc.narg(recurrence)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+-- name: ToggleTodo :one
+UPDATE todos
+SET done = NOT done
+WHERE id = $1
+RETURNING *;
+-- name: DeleteTodo :execrows
+DELETE FROM todos
+WHERE id = $1;
+-- name: CountTodosByGamer :one
+SELECT count(*) FILTER (WHERE NOT done) AS open,
+    count(*) FILTER (WHERE done) AS closed
+FROM todos
+WHERE user_id = $1;
+-- name: CountTodosPerGamer :many
+SELECT g.id AS gamer_id,
+    count(t.id) FILTER (WHERE NOT t.done) AS open,
+    count(t.id) FILTER (WHERE t.done) AS closed
+FROM gamers g
+    LEFT JOIN todos t ON t.user_id = g.id
+GROUP BY g.id
+ORDER BY g.id;
+-- name: CopyGamers :copyfrom
+INSERT INTO gamers (first_name, last_name)
+VALUES ($1, $2);
+-- name: CopyTodos :copyfrom
 INSERT INTO todos (user_id, task, done)
-VALUES ($1, $2, $3)
-RETURNING *;
\ No newline at end of file
+VALUES ($1, $2, $3);
+-- name: ExistingGamerIDs :many
+SELECT id
+FROM gamers
+WHERE id = ANY(sqlc.arg(ids)::int []);
+-- name: CreateScore :one
+INSERT INTO scores (gamer_id, points, played_at)
+VALUES (
+        sqlc.arg(gamer_id),
+        sqlc.arg(points),
+        coalesce(sqlc.narg(played_at)::timestamptz, now())
+    )
+RETURNING *;
+-- name: ListLeaderboard :many
+SELECT l.gamer_id,
+    g.first_name,
+    g.last_name,
+    l.points,
+    l.matches,
+    l.rank,
+    l.position,
+    l.refreshed_at
+FROM leaderboard l
+    JOIN gamers g ON g.id = l.gamer_id
+WHERE l.period = sqlc.arg(period)
+    AND l.position > sqlc.arg(after_position)
+ORDER BY l.position
+LIMIT sqlc.arg(page_size);
+-- name: ListLeaderboardAroundGamer :many
+SELECT l.gamer_id,
+    g.first_name,
+    g.last_name,
+    l.points,
+    l.matches,
+    l.rank,
+    l.position,
+    l.refreshed_at
+FROM leaderboard me
+    JOIN leaderboard l ON l.period = me.period
+    AND l.position BETWEEN me.position - sqlc.arg(spread) AND me.position + sqlc.arg(spread)
+    JOIN gamers g ON g.id = l.gamer_id
+WHERE me.period = sqlc.arg(period)
+    AND me.gamer_id = sqlc.arg(gamer_id)
+ORDER BY l.position;
+-- name: RefreshLeaderboard :exec
+REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard;
+-- name: SnapshotRanks :execrows
+INSERT INTO rank_snapshots (gamer_id, period, rank, points, taken_at)
+SELECT l.gamer_id,
+    l.period,
+    l.rank,
+    l.points,
+    l.refreshed_at
+FROM leaderboard l
+    LEFT JOIN LATERAL (
+        SELECT s.rank,
+            s.points
+        FROM rank_snapshots s
+        WHERE s.gamer_id = l.gamer_id
+            AND s.period = l.period
+        ORDER BY s.taken_at DESC
+        LIMIT 1
+    ) last ON true
+WHERE last.rank IS DISTINCT FROM l.rank
+    OR last.points IS DISTINCT FROM l.points;
+-- name: ListRankHistory :many
+SELECT rank,
+    points,
+    taken_at
+FROM rank_snapshots
+WHERE gamer_id = sqlc.arg(gamer_id)
+    AND period = sqlc.arg(period)
+    AND taken_at >= sqlc.arg(since)
+ORDER BY taken_at;
+
+-- name: GetGamersByIDs :many
+SELECT *
+FROM gamers
+WHERE id = ANY(sqlc.arg(ids)::int []);
+-- name: RequestFriendship :one
+-- a request towards someone who already asked us accepts theirs. any other
+-- existing row (pending, accepted or blocked) is left alone and nothing is
+-- returned.
+INSERT INTO friendships (requester_id, addressee_id)
+VALUES (sqlc.arg(requester_id), sqlc.arg(addressee_id)) ON CONFLICT (
+        least(requester_id, addressee_id),
+        greatest(requester_id, addressee_id)
+    ) DO
+UPDATE
+SET status = 'accepted',
+    updated_at = now()
+WHERE friendships.status = 'pending'
+    AND friendships.requester_id = excluded.addressee_id
+RETURNING *;
+-- name: AcceptFriendship :one
+UPDATE friendships
+SET status = 'accepted',
+    updated_at = now()
+WHERE requester_id = sqlc.arg(requester_id)
+    AND addressee_id = sqlc.arg(addressee_id)
+    AND status = 'pending'
+RETURNING *;
+-- name: BlockGamer :one
+-- turns whatever is between the two gamers into a block by blocker_id, unless
+-- the other gamer blocked first.
+INSERT INTO friendships (requester_id, addressee_id, status)
+VALUES (sqlc.arg(blocker_id), sqlc.arg(blocked_id), 'blocked') ON CONFLICT (
+        least(requester_id, addressee_id),
+        greatest(requester_id, addressee_id)
+    ) DO
+UPDATE
+SET requester_id = excluded.requester_id,
+    addressee_id = excluded.addressee_id,
+    status = 'blocked',
+    updated_at = now()
+WHERE friendships.status <> 'blocked'
+    OR friendships.requester_id = excluded.requester_id
+RETURNING *;
+-- name: RemoveFriendship :execrows
+-- declines or cancels a request, unfriends, or lifts a block. Only the
+-- blocker can lift a block.
+DELETE FROM friendships
+WHERE least(requester_id, addressee_id) = least(sqlc.arg(gamer_id)::int, sqlc.arg(other_id)::int)
+    AND greatest(requester_id, addressee_id) = greatest(sqlc.arg(gamer_id)::int, sqlc.arg(other_id)::int)
+    AND (
+        status <> 'blocked'
+        OR requester_id = sqlc.arg(gamer_id)::int
+    );
+-- name: ListFriends :many
+SELECT g.*
+FROM friend_edges e
+    JOIN gamers g ON g.id = e.friend_id
+WHERE e.gamer_id = $1
+ORDER BY g.last_name,
+    g.first_name,
+    g.id;
+-- name: ListIncomingFriendRequests :many
+SELECT g.*,
+    f.created_at AS requested_at
+FROM friendships f
+    JOIN gamers g ON g.id = f.requester_id
+WHERE f.addressee_id = $1
+    AND f.status = 'pending'
+ORDER BY f.created_at;
+-- name: SuggestFriends :many
+-- walks the friends graph up to max_depth hops and suggests everyone reached
+-- in two or more hops that has no friendship, request or block with the
+-- gamer yet. DISTINCT in the recursive term applies to each hop on its own,
+-- so a gamer is carried at most once per hop however many paths lead to
+-- it; distance is the first hop that reached it. mutual_friends is the
+-- number of friends in common.
+WITH RECURSIVE reach (gamer_id, depth) AS (
+    SELECT sqlc.arg(gamer_id)::int,
+        0
+    UNION ALL
+    SELECT DISTINCT e.friend_id,
+        r.depth + 1
+    FROM reach r
+        JOIN friend_edges e ON e.gamer_id = r.gamer_id
+    WHERE r.depth < sqlc.arg(max_depth)::int
+),
+candidates AS (
+    SELECT gamer_id,
+        min(depth) AS distance
+    FROM reach
+    WHERE gamer_id <> sqlc.arg(gamer_id)::int
+    GROUP BY gamer_id
+)
+SELECT g.id,
+    g.first_name,
+    g.last_name,
+    c.distance::int AS distance,
+    (
+        SELECT count(*)
+        FROM friend_edges mine
+            JOIN friend_edges theirs ON theirs.gamer_id = mine.friend_id
+        WHERE mine.gamer_id = sqlc.arg(gamer_id)::int
+            AND theirs.friend_id = c.gamer_id
+    ) AS mutual_friends
+FROM candidates c
+    JOIN gamers g ON g.id = c.gamer_id
+WHERE c.distance >= 2
+    AND NOT EXISTS (
+        SELECT 1
+        FROM friendships f
+        WHERE least(f.requester_id, f.addressee_id) = least(c.gamer_id, sqlc.arg(gamer_id)::int)
+            AND greatest(f.requester_id, f.addressee_id) = greatest(c.gamer_id, sqlc.arg(gamer_id)::int)
+    )
+ORDER BY distance,
+    mutual_friends DESC,
+    g.id
+LIMIT sqlc.arg(page_size);
+-- name: ShortestFriendPath :one
+-- breadth first over friend_edges from from_id. DISTINCT ON in the
+-- recursive term keeps one path per gamer and hop, so the walk holds at
+-- most one row per gamer within reach at each hop instead of every path.
+-- Paths never revisit a gamer. Returns the gamer ids from from_id to to_id,
+-- or no rows when they aren't connected within max_hops.
+WITH RECURSIVE walk (gamer_id, path) AS (
+    SELECT sqlc.arg(from_id)::int,
+        ARRAY [sqlc.arg(from_id)::int]
+    UNION ALL
+    SELECT DISTINCT ON (e.friend_id) e.friend_id,
+        w.path || e.friend_id
+    FROM walk w
+        JOIN friend_edges e ON e.gamer_id = w.gamer_id
+    WHERE cardinality(w.path) <= sqlc.arg(max_hops)::int
+        AND w.gamer_id <> sqlc.arg(to_id)::int
+        AND NOT e.friend_id = ANY(w.path)
+)
+SELECT path::int []
+FROM walk
+WHERE gamer_id = sqlc.arg(to_id)::int
+ORDER BY cardinality(path)
+LIMIT 1;
+
+-- name: CreateNextOccurrence :one
+-- returns no rows when the occurrence already exists
+INSERT INTO todos (
+        user_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence,
+        series_id,
+        series_start,
+        occurrence
+    )
+VALUES (
+        sqlc.arg(user_id),
+        sqlc.arg(task),
+        false,
+        sqlc.arg(due_at),
+        sqlc.arg(time_zone),
+        sqlc.arg(priority),
+        sqlc.arg(recurrence),
+        sqlc.arg(series_id),
+        sqlc.arg(series_start),
+        sqlc.arg(occurrence)
+    ) ON CONFLICT (series_id, occurrence) DO NOTHING
+RETURNING *;
+-- name: CopyRemindersToOccurrence :execrows
+-- gives the next occurrence the same reminders, moved by as much as the due
+-- date moved
+INSERT INTO todo_reminders (todo_id, remind_at)
+SELECT sqlc.arg(to_todo_id)::int,
+    remind_at + (
+        sqlc.arg(to_due_at)::timestamptz - sqlc.arg(from_due_at)::timestamptz
+    )
+FROM todo_reminders
+WHERE todo_id = sqlc.arg(from_todo_id)::int;
+-- name: CreateTodoReminder :one
+INSERT INTO todo_reminders (todo_id, remind_at)
+VALUES ($1, $2)
+RETURNING *;
+-- name: ListTodoReminders :many
+SELECT *
+FROM todo_reminders
+WHERE todo_id = $1
+ORDER BY remind_at;
+-- name: DeleteTodoReminder :execrows
+DELETE FROM todo_reminders
+WHERE id = $1
+    AND todo_id = $2;
+-- name: ClaimDueReminders :many
+-- leases a batch of due reminders until now() + lease_seconds. SKIP LOCKED
+-- lets several workers claim at once without waiting on each other, the
+-- lease keeps the batch away from them while it is being sent. Failed
+-- reminders are retried attempts^2 minutes after remind_at.
+UPDATE todo_reminders r
+SET claimed_until = now() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+FROM todos t
+WHERE t.id = r.todo_id
+    AND r.id IN (
+        SELECT d.id
+        FROM todo_reminders d
+            JOIN todos dt ON dt.id = d.todo_id
+        WHERE d.sent_at IS NULL
+            AND (
+                d.claimed_until IS NULL
+                OR d.claimed_until <= now()
+            )
+            AND d.remind_at + make_interval(mins => d.attempts * d.attempts) <= now()
+            AND d.attempts < sqlc.arg(max_attempts)::int
+            AND NOT dt.done
+        ORDER BY d.remind_at
+        LIMIT sqlc.arg(batch_size) FOR
+        UPDATE OF d SKIP LOCKED
+    )
+RETURNING r.id,
+    r.todo_id,
+    r.remind_at,
+    r.attempts,
+    t.user_id,
+    t.task,
+    t.due_at,
+    t.time_zone,
+    t.priority;
+-- name: MarkReminderSent :exec
+UPDATE todo_reminders
+SET sent_at = now(),
+    attempts = attempts + 1,
+    last_error = NULL,
+    claimed_until = NULL
+WHERE id = $1;
+-- name: MarkReminderFailed :exec
+UPDATE todo_reminders
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error)::text,
+    claimed_until = NULL
+WHERE id = sqlc.arg(id);
+-- name: LockTodoOrder :exec
+-- serializes changes to a gamer's todo order until the transaction ends,
+-- the insert trigger takes the same lock
+SELECT pg_advisory_xact_lock('todos'::regclass::oid::int, sqlc.arg(user_id)::int);
+-- name: NextTodoPosition :one
+SELECT position
+FROM todos
+WHERE user_id = sqlc.arg(user_id)
+    AND position > sqlc.arg(position)
+    AND id <> sqlc.arg(exclude_id)
+ORDER BY position
+LIMIT 1;
+-- name: PrevTodoPosition :one
+SELECT position
+FROM todos
+WHERE user_id = sqlc.arg(user_id)
+    AND position < sqlc.arg(position)
+    AND id <> sqlc.arg(exclude_id)
+ORDER BY position DESC
+LIMIT 1;
+-- name: UpdateTodoPosition :one
+UPDATE todos
+SET position = $2
+WHERE id = $1
+RETURNING *;
+-- name: GamersWithLongTodoPositions :many
+-- the predicate is the one of todos_long_position_user_id_idx, keep them
+-- and service.RebalanceLength in sync
+SELECT DISTINCT user_id
+FROM todos
+WHERE length(position) > 12
+LIMIT sqlc.arg(max_gamers);
+-- name: ListTodoIDsByPosition :many
+SELECT id
+FROM todos
+WHERE user_id = $1
+ORDER BY position;
+-- name: SetTodoPositions :exec
+-- positions[i] is the new key of ids[i]
+UPDATE todos
+SET position = (sqlc.arg(positions)::text [])[array_position(sqlc.arg(ids)::int [], id)]
+WHERE user_id = sqlc.arg(user_id)
+    AND id = ANY(sqlc.arg(ids)::int []);
+-- name: GetTodoByClientID :one
+SELECT *
+FROM todos
+WHERE client_id = $1 FOR
+UPDATE;
+-- name: GetTombstone :one
+-- only the gamer's own tombstones: a change naming another gamer's
+-- deleted todo is rejected, like one naming their live todo
+SELECT *
+FROM todo_tombstones
+WHERE user_id = sqlc.arg(user_id)
+    AND (
+        todo_id = sqlc.narg(todo_id)
+        OR client_id = sqlc.narg(client_id)
+    )
+LIMIT 1;
+-- name: CreateSyncedTodo :one
+INSERT INTO todos (
+        user_id,
+        client_id,
+        task,
+        done,
+        due_at,
+        time_zone,
+        priority,
+        recurrence,
+        field_updated_at
+    )
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+-- name: UpdateSyncedTodo :one
+UPDATE todos
+SET task = $2,
+    done = $3,
+    due_at = $4,
+    time_zone = $5,
+    priority = $6,
+    recurrence = $7,
+    field_updated_at = $8
+WHERE id = $1
+RETURNING *;
+-- name: ListTodosChangedSince :many
+SELECT *
+FROM todos
+WHERE user_id = sqlc.arg(user_id)
+    AND version > sqlc.arg(version)
+ORDER BY version
+LIMIT sqlc.arg(max_rows);
+-- name: ListTombstonesSince :many
+SELECT *
+FROM todo_tombstones
+WHERE user_id = sqlc.arg(user_id)
+    AND version > sqlc.arg(version)
+ORDER BY version
+LIMIT sqlc.arg(max_rows);
@@ -0,0 +1,54 @@
+-- migrate:up
+-- rows inserted so far have no id, number them after the highest one
+UPDATE dbmate
+SET id = numbered.id
+FROM (
+        SELECT ctid,
+            coalesce((SELECT max(id) FROM dbmate), 0) + row_number() OVER () AS id
+        FROM dbmate
+        WHERE id IS NULL
+    ) numbered
+WHERE dbmate.ctid = numbered.ctid;
+ALTER TABLE dbmate
+ALTER COLUMN id
+SET NOT NULL,
+    ALTER COLUMN id
+ADD GENERATED BY DEFAULT AS IDENTITY,
+    ADD PRIMARY KEY (id);
+SELECT setval(
+        pg_get_serial_sequence('dbmate', 'id'),
+        coalesce(max(id), 0) + 1,
+        false
+    )
+FROM dbmate;
+-- emails are stored normalized (trimmed, lower case) by the user service,
+-- rows written before it may not be. They are normalized here, unless that
+-- would make two of them collide: which account to keep is for a person to
+-- decide, so the migration stops and lists them.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(normalized, ', ' ORDER BY normalized) INTO duplicates
+    FROM (
+            SELECT lower(btrim(email, E' \t\r\n')) AS normalized
+            FROM dbmate
+            GROUP BY 1
+            HAVING count(*) > 1
+        ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'emails that only differ in case or surrounding spaces: %', duplicates
+            USING HINT = 'merge or change these rows, then migrate again';
+    END IF;
+END;
+$$;
+UPDATE dbmate
+SET email = lower(btrim(email, E' \t\r\n'))
+WHERE email <> lower(btrim(email, E' \t\r\n'));
+ALTER TABLE dbmate
+ADD CONSTRAINT dbmate_email_key UNIQUE (email);
+-- migrate:down
+ALTER TABLE dbmate DROP CONSTRAINT dbmate_email_key,
+    DROP CONSTRAINT dbmate_pkey,
+    ALTER COLUMN id DROP IDENTITY,
+    ALTER COLUMN id DROP NOT NULL;
@@ -1,5 +1,26 @@
 -- name: GetUserByEmail :one
-SELECT * FROM dbmate WHERE email = $1;
+SELECT * FROM dbmate WHERE email = sqlc.arg(email);
 
--- name: CreateUser :exec
-INSERT INTO dbmate (name, email) VALUES ($1, $2);
+-- name: CreateUser :one
+INSERT INTO dbmate (name, email) VALUES (sqlc.arg(name), sqlc.arg(email)) RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM dbmate WHERE id = sqlc.arg(id);
+
+-- name: UpdateUserProfile :one
+-- fields left null keep their current value
+UPDATE dbmate
+SET name = coalesce(sqlc.narg(name), name),
+    age = coalesce(CAST(sqlc.narg(age) AS integer), age),
+    is_active = coalesce(CAST(sqlc.narg(is_active) AS boolean), is_active)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeactivateUser :one
+UPDATE dbmate SET is_active = false WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ListActiveUsers :many
+SELECT * FROM dbmate
+WHERE is_active AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,20 @@
+// Package sqlite embeds the SQLite versions of the migrations, so they can
+// be applied from anywhere, tests included.
+package sqlite
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// Migrations returns the migration files, ready for migrate.NewFS.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(files, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
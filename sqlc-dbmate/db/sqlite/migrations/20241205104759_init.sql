@@ -0,0 +1,8 @@
+-- migrate:up
+CREATE TABLE dbmate (
+    id integer,
+    name varchar(255),
+    email varchar(255) NOT NULL
+);
+-- migrate:down
+DROP TABLE dbmate;
@@ -0,0 +1,6 @@
+-- migrate:up
+ALTER TABLE dbmate ADD COLUMN age INT DEFAULT 0;
+ALTER TABLE dbmate ADD COLUMN is_active BOOLEAN DEFAULT TRUE;
+-- migrate:down
+ALTER TABLE dbmate DROP COLUMN is_active;
+ALTER TABLE dbmate DROP COLUMN age;
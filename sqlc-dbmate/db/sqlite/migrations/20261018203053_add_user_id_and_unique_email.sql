@@ -0,0 +1,33 @@
+-- migrate:up
+-- SQLite can't add a primary key to a table, so it is rebuilt. Rows without
+-- an id get one after the highest id from INTEGER PRIMARY KEY. Emails are
+-- normalized (trimmed, lower case) on the way, as the user service stores
+-- them; two that collide fail the insert on dbmate_email_key, and the
+-- migration with it.
+CREATE TABLE dbmate_new (
+    id integer PRIMARY KEY,
+    name varchar(255),
+    email varchar(255) NOT NULL,
+    age INT DEFAULT 0,
+    is_active BOOLEAN DEFAULT TRUE,
+    CONSTRAINT dbmate_email_key UNIQUE (email)
+);
+INSERT INTO dbmate_new (id, name, email, age, is_active)
+SELECT id, name, lower(trim(email, ' ' || char(9, 10, 13))), age, is_active
+FROM dbmate
+ORDER BY id IS NULL, id;
+DROP TABLE dbmate;
+ALTER TABLE dbmate_new RENAME TO dbmate;
+-- migrate:down
+CREATE TABLE dbmate_old (
+    id integer,
+    name varchar(255),
+    email varchar(255) NOT NULL,
+    age INT DEFAULT 0,
+    is_active BOOLEAN DEFAULT TRUE
+);
+INSERT INTO dbmate_old (id, name, email, age, is_active)
+SELECT id, name, email, age, is_active
+FROM dbmate;
+DROP TABLE dbmate;
+ALTER TABLE dbmate_old RENAME TO dbmate;
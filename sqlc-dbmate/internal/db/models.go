@@ -9,7 +9,7 @@ import (
 )
 
 type Dbmate struct {
-	ID       sql.NullInt32
+	ID       int32
 	Name     sql.NullString
 	Email    string
 	Age      sql.NullInt32
@@ -0,0 +1,160 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO dbmate (name, email) VALUES (?1, ?2) RETURNING id, name, email, age, is_active
+`
+
+type CreateUserParams struct {
+	Name  sql.NullString
+	Email string
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Dbmate, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.Name, arg.Email)
+	var i Dbmate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Age,
+		&i.IsActive,
+	)
+	return i, err
+}
+
+const deactivateUser = `-- name: DeactivateUser :one
+UPDATE dbmate SET is_active = false WHERE id = ?1 RETURNING id, name, email, age, is_active
+`
+
+func (q *Queries) DeactivateUser(ctx context.Context, id int32) (Dbmate, error) {
+	row := q.db.QueryRowContext(ctx, deactivateUser, id)
+	var i Dbmate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Age,
+		&i.IsActive,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, name, email, age, is_active FROM dbmate WHERE id = ?1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int32) (Dbmate, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i Dbmate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Age,
+		&i.IsActive,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, name, email, age, is_active FROM dbmate WHERE email = ?1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (Dbmate, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i Dbmate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Age,
+		&i.IsActive,
+	)
+	return i, err
+}
+
+const listActiveUsers = `-- name: ListActiveUsers :many
+SELECT id, name, email, age, is_active FROM dbmate
+WHERE is_active AND id > ?1
+ORDER BY id
+LIMIT ?2
+`
+
+type ListActiveUsersParams struct {
+	AfterID  int32
+	PageSize int32
+}
+
+func (q *Queries) ListActiveUsers(ctx context.Context, arg ListActiveUsersParams) ([]Dbmate, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveUsers, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Dbmate
+	for rows.Next() {
+		var i Dbmate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Age,
+			&i.IsActive,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE dbmate
+SET name = coalesce(?1, name),
+    age = coalesce(CAST(?2 AS integer), age),
+    is_active = coalesce(CAST(?3 AS boolean), is_active)
+WHERE id = ?4
+RETURNING id, name, email, age, is_active
+`
+
+type UpdateUserProfileParams struct {
+	Name     sql.NullString
+	Age      sql.NullInt32
+	IsActive sql.NullBool
+	ID       int32
+}
+
+// fields left null keep their current value
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (Dbmate, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Name,
+		arg.Age,
+		arg.IsActive,
+		arg.ID,
+	)
+	var i Dbmate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Age,
+		&i.IsActive,
+	)
+	return i, err
+}
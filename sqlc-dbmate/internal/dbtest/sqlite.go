@@ -0,0 +1,49 @@
+// Package dbtest provides databases for tests. It isn't a _test package so
+// tests of every package can share it.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"sqlc-dbmate/db/sqlite"
+	"sqlc-dbmate/internal/db"
+	"sqlc-dbmate/internal/migrate"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite returns a fresh in-memory SQLite database with every migration
+// applied. It is closed, and gone, when the test ends.
+//
+// The queries in internal/db are written to run on SQLite as well, so
+// db.New(dbtest.SQLite(t)) is a stand-in for Postgres in unit tests.
+func SQLite(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	conn, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		tb.Fatalf("dbtest: open sqlite: %v", err)
+	}
+	// every connection to :memory: opens its own empty database, so the pool
+	// must keep exactly one. Code that holds a transaction open while
+	// querying through the pool blocks, keep such queries on the tx.
+	conn.SetMaxOpenConns(1)
+	conn.SetConnMaxLifetime(0)
+	conn.SetConnMaxIdleTime(0)
+	tb.Cleanup(func() { conn.Close() })
+
+	migrator := migrate.NewFS(conn, sqlite.Migrations())
+	migrator.Dialect = migrate.SQLite
+	if err := migrator.Up(context.Background()); err != nil {
+		tb.Fatalf("dbtest: migrate sqlite: %v", err)
+	}
+
+	return conn
+}
+
+// Queries is db.New on a fresh SQLite database.
+func Queries(tb testing.TB) *db.Queries {
+	tb.Helper()
+	return db.New(SQLite(tb))
+}
@@ -0,0 +1,147 @@
+// Package drift finds differences between what the migrations produce and
+// what is committed: the dumped schema file and the structs sqlc generated.
+// Either can go stale when a migration changes without running dump or
+// sqlc generate afterwards.
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sqlc-dbmate/internal/migrate"
+)
+
+type Options struct {
+	// DatabaseURL is used to create and drop the scratch database, the
+	// database it points at is left alone.
+	DatabaseURL   string
+	MigrationsDir string
+	SchemaFile    string
+	ModelsFile    string
+}
+
+type Report struct {
+	SchemaFile string
+	// SchemaMissing is set when the schema file doesn't exist.
+	SchemaMissing bool
+	// SchemaDiff goes from the committed schema to the migrated one.
+	SchemaDiff []string
+	ModelsFile string
+	Models     []Mismatch
+}
+
+func (r *Report) OK() bool {
+	return !r.SchemaMissing && len(r.SchemaDiff) == 0 && len(r.Models) == 0
+}
+
+// Check applies every migration to a scratch database and compares the
+// result with the committed schema file and models.
+func Check(ctx context.Context, opts Options) (*Report, error) {
+	report := &Report{SchemaFile: opts.SchemaFile, ModelsFile: opts.ModelsFile}
+
+	structs, err := modelStructs(opts.ModelsFile)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := os.ReadFile(opts.SchemaFile)
+	if errors.Is(err, os.ErrNotExist) {
+		report.SchemaMissing = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	scratchURL, drop, err := scratchDatabase(ctx, opts.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer drop()
+
+	scratch, err := sql.Open("pgx", scratchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+
+	if err := migrate.New(scratch, opts.MigrationsDir).Up(ctx); err != nil {
+		return nil, fmt.Errorf("apply migrations to scratch database: %w", err)
+	}
+
+	if !report.SchemaMissing {
+		dump, err := dumpToString(ctx, scratch, scratchURL)
+		if err != nil {
+			return nil, err
+		}
+		report.SchemaDiff = diffLines(normalizeDump(string(committed)), normalizeDump(dump), 3)
+	}
+
+	cols, err := columns(ctx, scratch)
+	if err != nil {
+		return nil, err
+	}
+	report.Models = compareModels(cols, structs)
+
+	return report, nil
+}
+
+func dumpToString(ctx context.Context, db *sql.DB, databaseURL string) (string, error) {
+	f, err := os.CreateTemp("", "schema-*.sql")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := migrate.Dump(ctx, db, databaseURL, f.Name()); err != nil {
+		return "", err
+	}
+	dump, err := os.ReadFile(f.Name())
+	return string(dump), err
+}
+
+// WriteTo prints the report for people, with what to run to fix each part.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	switch {
+	case r.SchemaMissing:
+		fmt.Fprintf(cw, "%s does not exist, run dump to create it\n", r.SchemaFile)
+	case len(r.SchemaDiff) > 0:
+		fmt.Fprintf(cw, "%s does not match the migrations (- committed, + migrated), run dump to update it:\n\n", r.SchemaFile)
+		for _, line := range r.SchemaDiff {
+			fmt.Fprintf(cw, "  %s\n", line)
+		}
+	default:
+		fmt.Fprintf(cw, "%s matches the migrations\n", r.SchemaFile)
+	}
+	fmt.Fprintln(cw)
+
+	if len(r.Models) > 0 {
+		fmt.Fprintf(cw, "%s does not match the migrations, run sqlc generate to update it:\n\n", r.ModelsFile)
+		for _, m := range r.Models {
+			fmt.Fprintf(cw, "  %s\n", m)
+		}
+	} else {
+		fmt.Fprintf(cw, "%s matches the migrations\n", r.ModelsFile)
+	}
+
+	return cw.n, cw.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,89 @@
+package drift
+
+import (
+	"strings"
+)
+
+// normalizeDump drops what differs between two dumps of the same schema:
+// comments, which include the server and pg_dump versions, blank lines and
+// psql meta commands such as \restrict.
+func normalizeDump(dump string) []string {
+	var lines []string
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, `\`) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// diffLines returns a minimal line diff of a and b: unchanged lines start
+// with a space, removed ones with "-" and added ones with "+". Unchanged
+// lines further than context away from a change are left out, gaps are
+// marked with "...". Schemas are small enough for the quadratic LCS table.
+func diffLines(a, b []string, context int) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var all []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			all = append(all, " "+a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			all = append(all, "+"+b[j])
+			j++
+		default:
+			all = append(all, "-"+a[i])
+			i++
+		}
+	}
+
+	keep := make([]bool, len(all))
+	changed := false
+	for n, line := range all {
+		if line[0] == ' ' {
+			continue
+		}
+		changed = true
+		for k := max(0, n-context); k <= min(len(all)-1, n+context); k++ {
+			keep[k] = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var out []string
+	skipped := false
+	for n, line := range all {
+		if !keep[n] {
+			skipped = true
+			continue
+		}
+		if skipped && len(out) > 0 {
+			out = append(out, "...")
+		}
+		skipped = false
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,244 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type column struct {
+	Table    string
+	Name     string
+	Type     string // udt_name, e.g. int4 or varchar
+	Nullable bool
+}
+
+// columns lists the columns of every table in the public schema, except
+// dbmate's own schema_migrations, in table and column order.
+func columns(ctx context.Context, db *sql.DB) ([]column, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.table_name, c.column_name, c.udt_name, c.is_nullable = 'YES'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t USING (table_schema, table_name)
+		WHERE c.table_schema = 'public'
+			AND t.table_type = 'BASE TABLE'
+			AND c.table_name <> 'schema_migrations'
+		ORDER BY c.table_name, c.ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []column
+	for rows.Next() {
+		var c column
+		if err := rows.Scan(&c.Table, &c.Name, &c.Type, &c.Nullable); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+type field struct {
+	Name string
+	Type string
+}
+
+// modelStructs reads the struct types of a sqlc generated models.go, with
+// their fields in order and the field types as written in the source.
+func modelStructs(path string) (map[string][]field, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := map[string][]field{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			var fields []field
+			for _, f := range st.Fields.List {
+				typ := exprString(f.Type)
+				for _, name := range f.Names {
+					fields = append(fields, field{Name: name.Name, Type: typ})
+				}
+			}
+			structs[ts.Name.Name] = fields
+		}
+	}
+	return structs, nil
+}
+
+func exprString(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+// goTypes maps Postgres types to the types sqlc generates for database/sql,
+// not null first and nullable second. Types missing here aren't checked.
+var goTypes = map[string][2]string{
+	"int2":        {"int16", "sql.NullInt16"},
+	"int4":        {"int32", "sql.NullInt32"},
+	"int8":        {"int64", "sql.NullInt64"},
+	"float4":      {"float32", "sql.NullFloat64"},
+	"float8":      {"float64", "sql.NullFloat64"},
+	"numeric":     {"string", "sql.NullString"},
+	"bool":        {"bool", "sql.NullBool"},
+	"text":        {"string", "sql.NullString"},
+	"varchar":     {"string", "sql.NullString"},
+	"bpchar":      {"string", "sql.NullString"},
+	"date":        {"time.Time", "sql.NullTime"},
+	"timestamp":   {"time.Time", "sql.NullTime"},
+	"timestamptz": {"time.Time", "sql.NullTime"},
+	"uuid":        {"uuid.UUID", "uuid.NullUUID"},
+	"bytea":       {"[]byte", "[]byte"},
+}
+
+// Mismatch is a difference between a table and its sqlc model.
+type Mismatch struct {
+	Table   string
+	Column  string
+	Problem string
+}
+
+func (m Mismatch) String() string {
+	if m.Column == "" {
+		return m.Table + ": " + m.Problem
+	}
+	return m.Table + "." + m.Column + ": " + m.Problem
+}
+
+// compareModels checks every table against the struct sqlc would generate
+// for it, and reports model structs left over from dropped tables.
+func compareModels(cols []column, structs map[string][]field) []Mismatch {
+	var mismatches []Mismatch
+
+	byTable := map[string][]column{}
+	var tables []string
+	for _, c := range cols {
+		if _, ok := byTable[c.Table]; !ok {
+			tables = append(tables, c.Table)
+		}
+		byTable[c.Table] = append(byTable[c.Table], c)
+	}
+
+	seen := map[string]bool{}
+	for _, table := range tables {
+		name := structName(table)
+		fields, ok := structs[name]
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Table: table, Problem: fmt.Sprintf("no %s struct in the models", name)})
+			continue
+		}
+		seen[name] = true
+
+		byName := map[string]field{}
+		for _, f := range fields {
+			byName[f.Name] = f
+		}
+		want := map[string]bool{}
+		for _, c := range byTable[table] {
+			fname := fieldName(c.Name)
+			want[fname] = true
+			f, ok := byName[fname]
+			if !ok {
+				mismatches = append(mismatches, Mismatch{Table: table, Column: c.Name, Problem: fmt.Sprintf("missing field %s.%s", name, fname)})
+				continue
+			}
+			types, known := goTypes[c.Type]
+			if !known {
+				continue
+			}
+			typ, null := types[0], "NOT NULL"
+			if c.Nullable {
+				typ, null = types[1], "nullable"
+			}
+			if f.Type != typ {
+				mismatches = append(mismatches, Mismatch{
+					Table:   table,
+					Column:  c.Name,
+					Problem: fmt.Sprintf("column is %s %s, so %s.%s should be %s, not %s", null, c.Type, name, fname, typ, f.Type),
+				})
+			}
+		}
+		for _, f := range fields {
+			if !want[f.Name] {
+				mismatches = append(mismatches, Mismatch{Table: table, Problem: fmt.Sprintf("field %s.%s has no column", name, f.Name)})
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(structs)) {
+		if !seen[name] && isTableModel(name, structs) {
+			mismatches = append(mismatches, Mismatch{Table: name, Problem: "struct has no table"})
+		}
+	}
+	return mismatches
+}
+
+// isTableModel guesses whether a struct in models.go belongs to a table.
+// sqlc also puts the Null types of enums there, which end in a Valid field.
+func isTableModel(name string, structs map[string][]field) bool {
+	fields := structs[name]
+	return !strings.HasPrefix(name, "Null") || len(fields) == 0 || fields[len(fields)-1].Name != "Valid"
+}
+
+// structName follows sqlc's naming: the table name singularized and camel
+// cased, e.g. user_accounts becomes UserAccount.
+func structName(table string) string {
+	switch {
+	case strings.HasSuffix(table, "ies"):
+		table = strings.TrimSuffix(table, "ies") + "y"
+	case strings.HasSuffix(table, "ss"):
+	case strings.HasSuffix(table, "s"):
+		table = strings.TrimSuffix(table, "s")
+	}
+	return fieldName(table)
+}
+
+// fieldName camel cases a column name like sqlc does with its default
+// initialisms, where only id is upper cased.
+func fieldName(column string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(column, "_") {
+		if part == "" {
+			continue
+		}
+		if part == "id" {
+			b.WriteString("ID")
+			continue
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
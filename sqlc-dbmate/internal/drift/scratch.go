@@ -0,0 +1,40 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// scratchDatabase creates an empty database next to the one databaseURL
+// points at and returns its URL and a function dropping it again. The user
+// in databaseURL needs the CREATEDB privilege.
+func scratchDatabase(ctx context.Context, databaseURL string) (string, func(), error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("parse database URL: %w", err)
+	}
+
+	admin, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// only digits are appended, so the name needs no quoting
+	name := fmt.Sprintf("sqlc_dbmate_check_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		admin.Close()
+		return "", nil, fmt.Errorf("create scratch database: %w", err)
+	}
+
+	drop := func() {
+		defer admin.Close()
+		// FORCE closes connections a failed check may have left behind
+		admin.ExecContext(context.WithoutCancel(ctx), "DROP DATABASE IF EXISTS "+name+" WITH (FORCE)")
+	}
+
+	u.Path = "/" + name
+	return u.String(), drop, nil
+}
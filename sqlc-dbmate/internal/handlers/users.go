@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sqlc-dbmate/internal/db"
+	"sqlc-dbmate/internal/service"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type UserHandler struct {
+	users *service.UserService
+}
+
+func NewUserHandler(users *service.UserService) *UserHandler {
+	return &UserHandler{users: users}
+}
+
+func (h *UserHandler) Register(e *echo.Echo) {
+	e.POST("/users", h.Signup)
+	e.GET("/users", h.ListUsers)
+	e.GET("/users/:id", h.GetUser)
+	e.PATCH("/users/:id", h.UpdateProfile)
+	e.POST("/users/:id/deactivate", h.Deactivate)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type userResponse struct {
+	ID       int32   `json:"id"`
+	Name     *string `json:"name"`
+	Email    string  `json:"email"`
+	Age      *int32  `json:"age"`
+	IsActive bool    `json:"is_active"`
+}
+
+func newUserResponse(u db.Dbmate) userResponse {
+	resp := userResponse{ID: u.ID, Email: u.Email, IsActive: u.IsActive.Valid && u.IsActive.Bool}
+	if u.Name.Valid {
+		resp.Name = &u.Name.String
+	}
+	if u.Age.Valid {
+		resp.Age = &u.Age.Int32
+	}
+	return resp
+}
+
+type signupRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (h *UserHandler) Signup(c echo.Context) error {
+	var req signupRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	user, err := h.users.Signup(c.Request().Context(), req.Name, req.Email)
+	if err != nil {
+		return serviceError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, newUserResponse(user))
+}
+
+func (h *UserHandler) GetUser(c echo.Context) error {
+	id, err := idParam(c)
+	if err != nil {
+		return badRequest(c, "invalid user id")
+	}
+
+	user, err := h.users.Get(c.Request().Context(), id)
+	if err != nil {
+		return serviceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type listUsersResponse struct {
+	Users []userResponse `json:"users"`
+	// NextAfter is the after parameter for the next page, nil on the last.
+	NextAfter *int32 `json:"next_after"`
+}
+
+// ListUsers lists active users by id, or looks one up with ?email=, which
+// also finds inactive users.
+func (h *UserHandler) ListUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	if email := c.QueryParam("email"); email != "" {
+		user, err := h.users.GetByEmail(ctx, email)
+		if errors.Is(err, service.ErrNotFound) {
+			return c.JSON(http.StatusOK, listUsersResponse{Users: []userResponse{}})
+		}
+		if err != nil {
+			return serviceError(c, err)
+		}
+		return c.JSON(http.StatusOK, listUsersResponse{Users: []userResponse{newUserResponse(user)}})
+	}
+
+	after, err := queryInt(c, "after", 0)
+	if err != nil || after < 0 {
+		return badRequest(c, "invalid after")
+	}
+	limit, err := queryInt(c, "limit", defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return badRequest(c, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+	}
+
+	users, err := h.users.ListActive(ctx, after, limit)
+	if err != nil {
+		return serviceError(c, err)
+	}
+
+	resp := listUsersResponse{Users: make([]userResponse, len(users))}
+	for i, u := range users {
+		resp.Users[i] = newUserResponse(u)
+	}
+	if len(users) == int(limit) {
+		resp.NextAfter = &users[len(users)-1].ID
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+type updateProfileRequest struct {
+	Name     *string `json:"name"`
+	Age      *int32  `json:"age"`
+	IsActive *bool   `json:"is_active"`
+}
+
+func (h *UserHandler) UpdateProfile(c echo.Context) error {
+	id, err := idParam(c)
+	if err != nil {
+		return badRequest(c, "invalid user id")
+	}
+	var req updateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return badRequest(c, "invalid request body")
+	}
+
+	user, err := h.users.UpdateProfile(c.Request().Context(), id, service.ProfileUpdate{
+		Name:     req.Name,
+		Age:      req.Age,
+		IsActive: req.IsActive,
+	})
+	if err != nil {
+		return serviceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func (h *UserHandler) Deactivate(c echo.Context) error {
+	id, err := idParam(c)
+	if err != nil {
+		return badRequest(c, "invalid user id")
+	}
+
+	user, err := h.users.Deactivate(c.Request().Context(), id)
+	if err != nil {
+		return serviceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// serviceError maps errors from the user service to a status code.
+func serviceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrEmailTaken):
+		return c.JSON(http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidEmail), errors.Is(err, service.ErrInvalidAge):
+		return c.JSON(http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+	}
+
+	c.Logger().Error(err)
+	return c.JSON(http.StatusInternalServerError, errorResponse{Error: "internal server error"})
+}
+
+func badRequest(c echo.Context, msg string) error {
+	return c.JSON(http.StatusBadRequest, errorResponse{Error: msg})
+}
+
+func idParam(c echo.Context) (int32, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	return int32(id), err
+}
+
+func queryInt(c echo.Context, name string, def int32) (int32, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	return int32(n), err
+}
@@ -0,0 +1,267 @@
+// Package lint checks Postgres migrations for changes that are risky to run
+// against a production database: locks held while an index builds, table
+// rewrites, lost data and migrations that can't be rolled back.
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sqlc-dbmate/internal/migrate"
+	"strings"
+)
+
+// Rule names, as used in findings and in Config.Allow.
+const (
+	MissingDown             = "missing-down"
+	IndexNotConcurrent      = "index-not-concurrent"
+	ConcurrentInTransaction = "concurrent-index-in-transaction"
+	AddColumnNotNull        = "add-column-not-null"
+	TableRewrite            = "table-rewrite"
+	DestructiveDrop         = "destructive-drop"
+)
+
+type Finding struct {
+	File    string
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Rule, f.Message)
+}
+
+// Config is read from a JSON file such as
+//
+//	{
+//		"large_tables": ["dbmate"],
+//		"allow": {"20261018203053": ["index-not-concurrent"]}
+//	}
+type Config struct {
+	// LargeTables are the tables where building an index without
+	// CONCURRENTLY blocks writes for too long. Empty means every table.
+	LargeTables []string `json:"large_tables"`
+	// Allow maps a migration version, or its file name, to the rules it
+	// is allowed to break. "*" allows every rule.
+	Allow map[string][]string `json:"allow"`
+}
+
+// ReadConfig reads the config at path. A missing file is the empty config.
+func ReadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c Config) allowed(m migrate.Migration, rule string) bool {
+	for _, key := range []string{m.Version, m.FileName} {
+		rules := c.Allow[key]
+		if slices.Contains(rules, rule) || slices.Contains(rules, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) large(table string) bool {
+	return len(c.LargeTables) == 0 || slices.Contains(c.LargeTables, table)
+}
+
+// Dir lints every migration in dir.
+func Dir(dir string, cfg Config) ([]Finding, error) {
+	migrations, err := migrate.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, m := range migrations {
+		findings = append(findings, Migration(m, cfg)...)
+	}
+	return findings, nil
+}
+
+// Migration lints one migration. Only the up section is checked for risky
+// statements, a down section is expected to undo and drop things.
+func Migration(m migrate.Migration, cfg Config) []Finding {
+	l := &linter{migration: m, cfg: cfg, created: map[string]bool{}}
+
+	if m.Down.SQL == "" {
+		l.report(m.Up.Line, MissingDown, "no -- migrate:down section, the migration can't be rolled back")
+	}
+
+	for _, stmt := range splitStatements(m.Up.SQL, m.Up.Line) {
+		l.statement(stmt, m.Up.Transaction)
+	}
+	return l.findings
+}
+
+type linter struct {
+	migration migrate.Migration
+	cfg       Config
+	// created holds the tables created by this migration. They are new and
+	// empty, so locking or rewriting them is harmless.
+	created  map[string]bool
+	findings []Finding
+}
+
+func (l *linter) report(line int, rule, format string, args ...any) {
+	if l.cfg.allowed(l.migration, rule) {
+		return
+	}
+	l.findings = append(l.findings, Finding{
+		File:    l.migration.FileName,
+		Line:    line,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// risky reports whether changes to table matter, which they don't for a
+// table the migration created itself.
+func (l *linter) risky(table string) bool {
+	return !l.created[table]
+}
+
+const ident = `((?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))?)`
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE (?:(?:GLOBAL |LOCAL )?(?:TEMP|TEMPORARY|UNLOGGED) )?TABLE (?:IF NOT EXISTS )?` + ident)
+	createIndexPattern = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?(?:IF NOT EXISTS )?(?:` + ident + ` )?ON (?:ONLY )?` + ident)
+	alterTablePattern  = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?` + ident + ` (.*)$`)
+	dropPattern        = regexp.MustCompile(`(?i)^DROP (TABLE|SCHEMA|DATABASE|MATERIALIZED VIEW) (?:IF EXISTS )?(.*?)(?: CASCADE| RESTRICT)?$`)
+	truncatePattern    = regexp.MustCompile(`(?i)^TRUNCATE (?:TABLE )?(?:ONLY )?(.*?)(?: RESTART IDENTITY| CONTINUE IDENTITY)?(?: CASCADE| RESTRICT)?$`)
+	rewriteCommand     = regexp.MustCompile(`(?i)^(VACUUM \(?FULL|CLUSTER)\b`)
+
+	addColumnPattern  = regexp.MustCompile(`(?i)^ADD (?:COLUMN )?(?:IF NOT EXISTS )?` + ident + ` (.*)$`)
+	columnTypePattern = regexp.MustCompile(`(?i)^ALTER (?:COLUMN )?` + ident + ` (?:SET DATA )?TYPE\b`)
+	dropColumnPattern = regexp.MustCompile(`(?i)^DROP (?:COLUMN )?(?:IF EXISTS )?` + ident)
+	// ADD without COLUMN adds a column unless one of these follows
+	addConstraintPattern = regexp.MustCompile(`(?i)^ADD (CONSTRAINT|PRIMARY KEY|UNIQUE|CHECK|FOREIGN KEY|EXCLUDE)\b`)
+	addIndexPattern      = regexp.MustCompile(`(?i)^ADD (?:CONSTRAINT ` + ident + ` )?(PRIMARY KEY|UNIQUE|EXCLUDE)\b`)
+	rewriteAction        = regexp.MustCompile(`(?i)^SET (TABLESPACE|LOGGED|UNLOGGED|ACCESS METHOD)\b`)
+
+	notNullPattern  = regexp.MustCompile(`(?i)\bNOT NULL\b`)
+	defaultPattern  = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	identityPattern = regexp.MustCompile(`(?i)\bGENERATED (?:ALWAYS|BY DEFAULT) AS IDENTITY\b|\b(?:SMALL|BIG)?SERIAL\b`)
+	storedPattern   = regexp.MustCompile(`(?i)\bGENERATED ALWAYS AS \(.*\) STORED\b`)
+	volatileDefault = regexp.MustCompile(`(?i)\bDEFAULT .*\b(random|clock_timestamp|timeofday|gen_random_uuid|uuid_generate_v[14]|nextval)\s*\(`)
+)
+
+func (l *linter) statement(stmt statement, inTransaction bool) {
+	sql := stmt.SQL
+
+	if m := createTablePattern.FindStringSubmatch(sql); m != nil {
+		l.created[tableName(m[1])] = true
+		return
+	}
+
+	if m := createIndexPattern.FindStringSubmatch(sql); m != nil {
+		table := tableName(m[3])
+		concurrent := m[1] != ""
+		switch {
+		case concurrent && inTransaction:
+			l.report(stmt.Line, ConcurrentInTransaction, "CREATE INDEX CONCURRENTLY can't run in a transaction, use -- migrate:up transaction:false")
+		case !concurrent && l.risky(table) && l.cfg.large(table):
+			l.report(stmt.Line, IndexNotConcurrent, "index on %s is built without CONCURRENTLY, which blocks writes to the table until it is done", table)
+		}
+		return
+	}
+
+	if m := alterTablePattern.FindStringSubmatch(sql); m != nil {
+		table := tableName(m[1])
+		if !l.risky(table) {
+			return
+		}
+		for _, action := range splitTopLevel(m[2]) {
+			l.alterAction(stmt.Line, table, action)
+		}
+		return
+	}
+
+	if m := dropPattern.FindStringSubmatch(sql); m != nil {
+		for _, name := range splitTopLevel(m[2]) {
+			if l.created[tableName(name)] {
+				continue
+			}
+			l.report(stmt.Line, DestructiveDrop, "DROP %s %s deletes its data", strings.ToUpper(m[1]), name)
+		}
+		return
+	}
+
+	if m := truncatePattern.FindStringSubmatch(sql); m != nil {
+		l.report(stmt.Line, DestructiveDrop, "TRUNCATE %s deletes every row", m[1])
+		return
+	}
+
+	if m := rewriteCommand.FindStringSubmatch(sql); m != nil {
+		l.report(stmt.Line, TableRewrite, "%s rewrites the table under an ACCESS EXCLUSIVE lock", strings.ToUpper(strings.TrimRight(m[1], " (")))
+	}
+}
+
+func (l *linter) alterAction(line int, table, action string) {
+	if m := addIndexPattern.FindStringSubmatch(action); m != nil {
+		if l.cfg.large(table) && !strings.Contains(strings.ToUpper(action), " USING INDEX ") {
+			l.report(line, IndexNotConcurrent, "%s on %s builds its index while blocking writes, create the index CONCURRENTLY first and add the constraint USING INDEX", strings.ToUpper(m[2]), table)
+		}
+		return
+	}
+	if addConstraintPattern.MatchString(action) {
+		return
+	}
+
+	if m := addColumnPattern.FindStringSubmatch(action); m != nil {
+		column, def := m[1], m[2]
+		switch {
+		case identityPattern.MatchString(def):
+			l.report(line, TableRewrite, "adding identity or serial column %s.%s rewrites the table", table, column)
+		case storedPattern.MatchString(def):
+			l.report(line, TableRewrite, "adding stored generated column %s.%s rewrites the table", table, column)
+		case volatileDefault.MatchString(def):
+			l.report(line, TableRewrite, "adding %s.%s with a volatile default rewrites the table", table, column)
+		case notNullPattern.MatchString(def) && !defaultPattern.MatchString(def):
+			l.report(line, AddColumnNotNull, "adding NOT NULL column %s.%s without a default fails when the table has rows", table, column)
+		}
+		return
+	}
+
+	if m := columnTypePattern.FindStringSubmatch(action); m != nil {
+		l.report(line, TableRewrite, "changing the type of %s.%s usually rewrites the table and its indexes", table, m[1])
+		return
+	}
+
+	if m := rewriteAction.FindStringSubmatch(action); m != nil {
+		l.report(line, TableRewrite, "SET %s rewrites %s", strings.ToUpper(m[1]), table)
+		return
+	}
+
+	if m := dropColumnPattern.FindStringSubmatch(action); m != nil && !strings.HasPrefix(strings.ToUpper(action), "DROP CONSTRAINT") {
+		l.report(line, DestructiveDrop, "dropping %s.%s deletes its data", table, m[1])
+		return
+	}
+
+}
+
+// tableName drops the public schema and quotes, so the same table is always
+// spelled the same.
+func tableName(name string) string {
+	name = strings.TrimPrefix(name, "public.")
+	name = strings.TrimPrefix(name, `"public".`)
+	if strings.HasPrefix(name, `"`) {
+		return strings.Trim(name, `"`)
+	}
+	return strings.ToLower(name)
+}
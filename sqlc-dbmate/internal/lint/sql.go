@@ -0,0 +1,125 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// statement is one SQL statement with comments removed and whitespace
+// collapsed, so rules can match it with simple patterns.
+type statement struct {
+	SQL  string
+	Line int
+}
+
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// splitStatements splits sql on semicolons outside of quotes, comments and
+// dollar quoted bodies. line is the line sql starts on in the file.
+func splitStatements(sql string, line int) []statement {
+	var stmts []statement
+	var b strings.Builder
+	start := 0 // line of the current statement, 0 until it has content
+
+	emit := func() {
+		text := strings.Join(strings.Fields(b.String()), " ")
+		if text != "" {
+			stmts = append(stmts, statement{SQL: text, Line: start})
+		}
+		b.Reset()
+		start = 0
+	}
+	write := func(s string) {
+		if start == 0 && strings.TrimSpace(s) != "" {
+			start = line
+		}
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(sql); {
+		rest := sql[i:]
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			b.WriteByte(' ')
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest) - 4
+			}
+			line += strings.Count(rest[:end+4], "\n")
+			b.WriteByte(' ')
+			i += end + 4
+		case rest[0] == '\'' || rest[0] == '"':
+			end := closingQuote(rest, rest[0])
+			write(rest[:end])
+			line += strings.Count(rest[:end], "\n")
+			i += end
+		case rest[0] == '$' && dollarTag.MatchString(rest):
+			tag := dollarTag.FindString(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				end = len(rest) - 2*len(tag)
+			}
+			end += 2 * len(tag)
+			write(rest[:end])
+			line += strings.Count(rest[:end], "\n")
+			i += end
+		case rest[0] == ';':
+			emit()
+			i++
+		default:
+			if rest[0] == '\n' {
+				line++
+			}
+			write(rest[:1])
+			i++
+		}
+	}
+	emit()
+
+	return stmts
+}
+
+// closingQuote returns the length of the quoted string at the start of s,
+// where a doubled quote is an escaped one.
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// splitTopLevel splits s on commas outside parentheses and quotes, which
+// separates the actions of an ALTER TABLE.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\'', '"':
+			i += closingQuote(s[i:], s[i]) - 1
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[last:]))
+}
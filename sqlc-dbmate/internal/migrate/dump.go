@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Dump writes the schema to path in the format dbmate uses: pg_dump's
+// schema only output followed by the applied versions, so loading the file
+// gives a database that dbmate considers up to date. pg_dump has to be on
+// the PATH; there is no way to get a faithful schema dump without it.
+func Dump(ctx context.Context, db *sql.DB, databaseURL, path string) error {
+	cmd := exec.CommandContext(ctx, "pg_dump",
+		"--format=plain",
+		"--encoding=UTF8",
+		"--schema-only",
+		"--no-privileges",
+		"--no-owner",
+		databaseURL,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	schema, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("pg_dump: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return err
+		}
+		versions = append(versions, "    ('"+v+"')")
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.Write(schema)
+	out.WriteString("\n\n--\n-- Dbmate schema migrations\n--\n\n")
+	if len(versions) > 0 {
+		out.WriteString("INSERT INTO public.schema_migrations (version) VALUES\n")
+		out.WriteString(strings.Join(versions, ",\n"))
+		out.WriteString(";\n")
+	}
+
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
@@ -0,0 +1,164 @@
+// Package migrate runs dbmate migrations in process. It reads the same
+// files and keeps the same schema_migrations table as the dbmate binary, so
+// the two can be used interchangeably on one database.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var filePattern = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+// a section header, with optional options such as transaction:false
+var sectionPattern = regexp.MustCompile(`(?m)^--\s*migrate:(up|down)(.*)$`)
+
+type Migration struct {
+	Version  string
+	Name     string
+	FileName string
+	Up       Section
+	Down     Section
+}
+
+type Section struct {
+	SQL string
+	// Line is the line of the file SQL starts on.
+	Line int
+	// Transaction is false when the section header says transaction:false,
+	// for statements such as CREATE INDEX CONCURRENTLY.
+	Transaction bool
+}
+
+// ReadDir reads every migration in dir, sorted by version.
+func ReadDir(dir string) ([]Migration, error) {
+	return ReadFS(os.DirFS(dir))
+}
+
+// ReadFS reads every migration at the root of fsys, sorted by version.
+func ReadFS(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[string]string{}
+	for _, entry := range entries {
+		m := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		if other, ok := seen[m[1]]; ok {
+			return nil, fmt.Errorf("version %s is used by %s and %s", m[1], other, entry.Name())
+		}
+		seen[m[1]] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{
+			Version:  m[1],
+			Name:     m[2],
+			FileName: entry.Name(),
+			Up:       up,
+			Down:     down,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Parse splits a migration file into its up and down sections. The up
+// section is required, the down section may be missing or empty.
+func Parse(content string) (up, down Section, err error) {
+	headers := sectionPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(headers) == 0 || strings.TrimSpace(content[:headers[0][0]]) != "" {
+		return up, down, errors.New("file must start with -- migrate:up")
+	}
+
+	var haveUp, haveDown bool
+	for i, h := range headers {
+		end := len(content)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+
+		body := content[h[1]:end]
+		start := h[1] + len(body) - len(strings.TrimLeftFunc(body, unicode.IsSpace))
+		section := Section{
+			SQL:         strings.TrimSpace(body),
+			Line:        strings.Count(content[:start], "\n") + 1,
+			Transaction: true,
+		}
+		for _, opt := range strings.Fields(content[h[4]:h[5]]) {
+			switch opt {
+			case "transaction:false":
+				section.Transaction = false
+			case "transaction:true":
+			default:
+				return up, down, fmt.Errorf("unknown option %q", opt)
+			}
+		}
+
+		switch content[h[2]:h[3]] {
+		case "up":
+			if haveUp {
+				return up, down, errors.New("more than one -- migrate:up")
+			}
+			up, haveUp = section, true
+		case "down":
+			if haveDown {
+				return up, down, errors.New("more than one -- migrate:down")
+			}
+			down, haveDown = section, true
+		}
+	}
+
+	if !haveUp {
+		return up, down, errors.New("missing -- migrate:up")
+	}
+	return up, down, nil
+}
+
+// Create writes an empty migration for name to dir, versioned with the
+// current UTC time like dbmate does, and returns its path.
+func Create(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(strings.Join(strings.Fields(strings.ToLower(name)), "_"), "_")
+	if name == "" {
+		return "", errors.New("migration name is required")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, now.UTC().Format("20060102150405")+"_"+name+".sql")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("-- migrate:up\n\n\n-- migrate:down\n\n"); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,192 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// lockID identifies our advisory lock. dbmate itself doesn't lock, this
+// only keeps two runs of this tool from racing.
+const lockID int64 = 0x64626d617465 // "dbmate"
+
+// Dialect is the kind of database a Migrator works on.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	// SQLite databases are migrated without a lock, SQLite already allows
+	// only one writer at a time.
+	SQLite
+)
+
+// Migrator applies the migrations of one directory to a database.
+type Migrator struct {
+	db   *sql.DB
+	fsys fs.FS
+
+	// Dialect defaults to Postgres.
+	Dialect Dialect
+	// Log receives a line for every migration applied or reverted.
+	Log io.Writer
+}
+
+func New(db *sql.DB, dir string) *Migrator {
+	return NewFS(db, os.DirFS(dir))
+}
+
+// NewFS is New for migrations at the root of fsys, such as an embed.FS.
+func NewFS(db *sql.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys, Log: io.Discard}
+}
+
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// ensureTable creates schema_migrations exactly like dbmate does.
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version varchar(128) PRIMARY KEY
+	)`)
+	return err
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions[v] = true
+	}
+	return versions, rows.Err()
+}
+
+// withLock runs fn on a single connection holding the advisory lock, after
+// making sure schema_migrations exists.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, migrations []Migration, done map[string]bool) error) error {
+	migrations, err := ReadFS(m.fsys)
+	if err != nil {
+		return err
+	}
+
+	// session level advisory locks belong to a connection, so everything
+	// runs on this one
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID)
+	}
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	done, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, done)
+}
+
+// Up applies every pending migration in version order, including ones
+// older than the newest applied migration, as dbmate does.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, migrations []Migration, done map[string]bool) error {
+		for _, mig := range migrations {
+			if done[mig.Version] {
+				continue
+			}
+			fmt.Fprintf(m.Log, "Applying: %s\n", mig.FileName)
+			err := run(ctx, conn, mig.Up, "INSERT INTO schema_migrations (version) VALUES ($1)", mig.Version)
+			if err != nil {
+				return fmt.Errorf("%s: %w", mig.FileName, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, migrations []Migration, done map[string]bool) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			mig := migrations[i]
+			if !done[mig.Version] {
+				continue
+			}
+			if mig.Down.SQL == "" {
+				return fmt.Errorf("%s has no down section", mig.FileName)
+			}
+			fmt.Fprintf(m.Log, "Rolling back: %s\n", mig.FileName)
+			err := run(ctx, conn, mig.Down, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+			if err != nil {
+				return fmt.Errorf("%s: %w", mig.FileName, err)
+			}
+			return nil
+		}
+		return errors.New("no applied migrations to roll back")
+	})
+}
+
+// Status lists every migration file and whether it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []Migration, done map[string]bool) error {
+		for _, mig := range migrations {
+			statuses = append(statuses, Status{Migration: mig, Applied: done[mig.Version]})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// run executes the section and records the version change, both in one
+// transaction unless the section opted out of it.
+func run(ctx context.Context, conn *sql.Conn, section Section, record string, version string) error {
+	if !section.Transaction {
+		if section.SQL != "" {
+			if _, err := conn.ExecContext(ctx, section.SQL); err != nil {
+				return err
+			}
+		}
+		_, err := conn.ExecContext(ctx, record, version)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if section.SQL != "" {
+		if _, err := tx.ExecContext(ctx, section.SQL); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
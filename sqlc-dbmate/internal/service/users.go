@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"sqlc-dbmate/internal/db"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrNotFound     = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already taken")
+	ErrInvalidEmail = errors.New("invalid email")
+	ErrInvalidAge   = errors.New("age must be between 0 and 150")
+)
+
+const uniqueViolation = "23505"
+
+type UserService struct {
+	q *db.Queries
+}
+
+func NewUserService(q *db.Queries) *UserService {
+	return &UserService{q: q}
+}
+
+// NormalizeEmail trims and lower cases a plain address. Names like
+// "Jo <jo@example.com>" are rejected, only the address itself is stored.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email || len(email) > 255 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// Signup creates an active user. The unique constraint on email decides
+// between concurrent signups with the same address.
+func (s *UserService) Signup(ctx context.Context, name, email string) (db.Dbmate, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return db.Dbmate{}, err
+	}
+
+	user, err := s.q.CreateUser(ctx, db.CreateUserParams{
+		Name:  sql.NullString{String: strings.TrimSpace(name), Valid: strings.TrimSpace(name) != ""},
+		Email: email,
+	})
+	if isEmailTaken(err) {
+		return db.Dbmate{}, ErrEmailTaken
+	}
+	return user, err
+}
+
+// isEmailTaken recognizes the unique violation on email from Postgres and
+// from SQLite, which tests run on.
+func isEmailTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == uniqueViolation && pgErr.ConstraintName == "dbmate_email_key"
+	}
+	// SQLite names the columns, not the constraint
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), "dbmate.email")
+	}
+	return false
+}
+
+func (s *UserService) Get(ctx context.Context, id int32) (db.Dbmate, error) {
+	return notFound(s.q.GetUser(ctx, id))
+}
+
+func (s *UserService) GetByEmail(ctx context.Context, email string) (db.Dbmate, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return db.Dbmate{}, err
+	}
+	return notFound(s.q.GetUserByEmail(ctx, email))
+}
+
+// ProfileUpdate holds the fields to change, nil fields are left as they are.
+type ProfileUpdate struct {
+	Name     *string
+	Age      *int32
+	IsActive *bool
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, id int32, u ProfileUpdate) (db.Dbmate, error) {
+	arg := db.UpdateUserProfileParams{ID: id}
+	if u.Name != nil {
+		arg.Name = sql.NullString{String: strings.TrimSpace(*u.Name), Valid: true}
+	}
+	if u.Age != nil {
+		if *u.Age < 0 || *u.Age > 150 {
+			return db.Dbmate{}, ErrInvalidAge
+		}
+		arg.Age = sql.NullInt32{Int32: *u.Age, Valid: true}
+	}
+	if u.IsActive != nil {
+		arg.IsActive = sql.NullBool{Bool: *u.IsActive, Valid: true}
+	}
+
+	return notFound(s.q.UpdateUserProfile(ctx, arg))
+}
+
+// Deactivate marks the user inactive. Deactivating twice is not an error.
+func (s *UserService) Deactivate(ctx context.Context, id int32) (db.Dbmate, error) {
+	return notFound(s.q.DeactivateUser(ctx, id))
+}
+
+// ListActive returns up to limit active users with an id above afterID,
+// ordered by id. Pass the last id of a page to get the next one.
+func (s *UserService) ListActive(ctx context.Context, afterID, limit int32) ([]db.Dbmate, error) {
+	return s.q.ListActiveUsers(ctx, db.ListActiveUsersParams{AfterID: afterID, PageSize: limit})
+}
+
+func notFound(user db.Dbmate, err error) (db.Dbmate, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	return user, err
+}
@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"sqlc-dbmate/internal/db"
+	"sqlc-dbmate/internal/dbtest"
+	"sqlc-dbmate/internal/service"
+)
+
+func mustSignup(t *testing.T, s *service.UserService, name, email string) db.Dbmate {
+	t.Helper()
+	u, err := s.Signup(context.Background(), name, email)
+	if err != nil {
+		t.Fatalf("Signup(%q, %q): %v", name, email, err)
+	}
+	return u
+}
+
+func TestSignup(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewUserService(dbtest.Queries(t))
+
+	u := mustSignup(t, s, "  Ada ", "  Ada@Example.COM ")
+	if u.Email != "ada@example.com" {
+		t.Errorf("Email = %q, want it trimmed and lowercased", u.Email)
+	}
+	if !u.Name.Valid || u.Name.String != "Ada" {
+		t.Errorf("Name = %+v, want trimmed \"Ada\"", u.Name)
+	}
+
+	got, err := s.GetByEmail(ctx, "ADA@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if got.ID != u.ID {
+		t.Errorf("GetByEmail found id %d, want %d", got.ID, u.ID)
+	}
+
+	anon := mustSignup(t, s, "   ", "anon@example.com")
+	if anon.Name.Valid {
+		t.Errorf("blank name stored as %q, want NULL", anon.Name.String)
+	}
+
+	if _, err := s.Signup(ctx, "Ada again", "ADA@example.com"); !errors.Is(err, service.ErrEmailTaken) {
+		t.Errorf("Signup with a taken email: err = %v, want ErrEmailTaken", err)
+	}
+	if _, err := s.Signup(ctx, "Bob", "Bob <bob@example.com>"); !errors.Is(err, service.ErrInvalidEmail) {
+		t.Errorf("Signup with a display name: err = %v, want ErrInvalidEmail", err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewUserService(dbtest.Queries(t))
+	u := mustSignup(t, s, "Ada", "ada@example.com")
+
+	age := int32(36)
+	got, err := s.UpdateProfile(ctx, u.ID, service.ProfileUpdate{Age: &age})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if !got.Age.Valid || got.Age.Int32 != 36 {
+		t.Errorf("Age = %+v, want 36", got.Age)
+	}
+	if got.Name.String != "Ada" || got.Email != "ada@example.com" {
+		t.Errorf("fields left nil changed: %+v", got)
+	}
+
+	name := " Ada Lovelace "
+	got, err = s.UpdateProfile(ctx, u.ID, service.ProfileUpdate{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if got.Name.String != "Ada Lovelace" || got.Age.Int32 != 36 {
+		t.Errorf("after renaming = %+v, want the trimmed name and age kept", got)
+	}
+
+	bad := int32(151)
+	if _, err := s.UpdateProfile(ctx, u.ID, service.ProfileUpdate{Age: &bad}); !errors.Is(err, service.ErrInvalidAge) {
+		t.Errorf("age 151: err = %v, want ErrInvalidAge", err)
+	}
+	if _, err := s.UpdateProfile(ctx, u.ID+100, service.ProfileUpdate{Age: &age}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("unknown id: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewUserService(dbtest.Queries(t))
+	u := mustSignup(t, s, "Ada", "ada@example.com")
+
+	for range 2 {
+		got, err := s.Deactivate(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("Deactivate: %v", err)
+		}
+		if got.IsActive.Bool {
+			t.Errorf("IsActive = %+v after Deactivate", got.IsActive)
+		}
+	}
+	if _, err := s.Deactivate(ctx, u.ID+100); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("unknown id: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListActive(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewUserService(dbtest.Queries(t))
+
+	var ids []int32
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		ids = append(ids, mustSignup(t, s, "", email).ID)
+	}
+	if _, err := s.Deactivate(ctx, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages [][]int32
+	after := int32(0)
+	for {
+		page, err := s.ListActive(ctx, after, 2)
+		if err != nil {
+			t.Fatalf("ListActive(%d, 2): %v", after, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		var got []int32
+		for _, u := range page {
+			got = append(got, u.ID)
+		}
+		pages = append(pages, got)
+		after = got[len(got)-1]
+	}
+
+	want := [][]int32{{ids[0], ids[2]}, {ids[3], ids[4]}}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
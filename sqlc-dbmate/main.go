@@ -1,5 +1,204 @@
 package main
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sqlc-dbmate/internal/drift"
+	"sqlc-dbmate/internal/lint"
+	"sqlc-dbmate/internal/migrate"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+	_ "modernc.org/sqlite"
+)
+
+const usage = `usage: sqlc-dbmate [flags] <command>
+
+commands:
+  up          apply all pending migrations
+  down        roll back the most recent migration
+  status      list migrations and whether they are applied
+  new NAME    create a new migration file
+  dump        write the schema to the schema file
+  serve       run the user API on APP_PORT (default 8080)
+  lint        check the migrations for risky statements
+  check       apply the migrations to a scratch database and compare the
+              result with the schema file and the sqlc models
+
+flags:`
+
 func main() {
+	// like dbmate, pick up DATABASE_URL from .env when it's there
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Failed to load .env file: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sqlc-dbmate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	url := fs.String("url", os.Getenv("DATABASE_URL"), "database URL, sqlite:path for a SQLite file")
+	dir := fs.String("migrations-dir", "db/migrations", "directory with the migration files")
+	schemaFile := fs.String("schema-file", "db/schema.sql", "file written by dump")
+	lintConfig := fs.String("lint-config", "db/lint.json", "allowlist and large tables for lint")
+	modelsFile := fs.String("models-file", "internal/db/models.go", "models generated by sqlc, compared by check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+	command := fs.Arg(0)
+
+	// like dbmate, sqlite:path selects SQLite, which has its own migrations
+	path, isSQLite := strings.CutPrefix(*url, "sqlite:")
+	if isSQLite && !flagSet(fs, "migrations-dir") {
+		*dir = "db/sqlite/migrations"
+	}
+
+	// new and lint are the only commands that don't need the database
+	if command == "lint" {
+		return lintMigrations(*dir, *lintConfig)
+	}
+	if command == "new" {
+		if fs.NArg() != 2 {
+			return errors.New("new needs a migration name")
+		}
+		path, err := migrate.Create(*dir, fs.Arg(1), time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println("Creating migration:", path)
+		return nil
+	}
+
+	if *url == "" {
+		return errors.New("DATABASE_URL is not set")
+	}
+	driver, dsn, dialect := "pgx", *url, migrate.Postgres
+	if isSQLite {
+		driver, dsn, dialect = "sqlite", path, migrate.SQLite
+		if command == "dump" || command == "check" {
+			return fmt.Errorf("%s needs Postgres", command)
+		}
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator := migrate.New(db, *dir)
+	migrator.Dialect = dialect
+	migrator.Log = os.Stdout
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down", "rollback":
+		return migrator.Down(ctx)
+	case "status":
+		return printStatus(ctx, migrator)
+	case "dump":
+		if err := migrate.Dump(ctx, db, *url, *schemaFile); err != nil {
+			return err
+		}
+		fmt.Println("Writing:", *schemaFile)
+		return nil
+	case "serve":
+		return serve(ctx, db)
+	case "check":
+		report, err := drift.Check(ctx, drift.Options{
+			DatabaseURL:   *url,
+			MigrationsDir: *dir,
+			SchemaFile:    *schemaFile,
+			ModelsFile:    *modelsFile,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := report.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+		if !report.OK() {
+			return errors.New("schema drift found")
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func lintMigrations(dir, configPath string) error {
+	cfg, err := lint.ReadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	findings, err := lint.Dir(dir, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		fmt.Println(filepath.Join(dir, f.File) + strings.TrimPrefix(f.String(), f.File))
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%d lint finding(s), fix them or allow them in %s", len(findings), configPath)
+	}
+	return nil
+}
+
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	for _, s := range statuses {
+		mark := "[ ]"
+		if s.Applied {
+			mark = "[X]"
+			applied++
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", mark, s.FileName)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
 
+	fmt.Printf("\nApplied: %d\nPending: %d\n", applied, len(statuses)-applied)
+	return nil
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"sqlc-dbmate/internal/db"
+	"sqlc-dbmate/internal/handlers"
+	"sqlc-dbmate/internal/service"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// serve runs the user API until ctx is cancelled.
+func serve(ctx context.Context, conn *sql.DB) error {
+	appPort := os.Getenv("APP_PORT")
+	if appPort == "" {
+		appPort = "8080"
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		return err
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	users := service.NewUserService(db.New(conn))
+	handlers.NewUserHandler(users).Register(e)
+
+	errc := make(chan error, 1)
+	go func() {
+		err := e.Start(":" + appPort)
+		if err != nil && err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return e.Shutdown(shutdownCtx)
+}